@@ -0,0 +1,160 @@
+package cacheaside
+
+import (
+	"context"
+	"time"
+
+	"github.com/erkesi/cacheaside/cache"
+	"golang.org/x/sync/errgroup"
+)
+
+const defaultConcurrency = 4
+
+// chunk 按 size 切分 s，size <= 0 时不切分
+func chunk[T any](s []T, size int) [][]T {
+	if size <= 0 || len(s) <= size {
+		return [][]T{s}
+	}
+	chunks := make([][]T, 0, (len(s)+size-1)/size)
+	for size < len(s) {
+		s, chunks = s[size:], append(chunks, s[:size:size])
+	}
+	return append(chunks, s)
+}
+
+// parallel 并发执行 n 个任务，并发数由 WithConcurrency 限制，任一任务失败时取消其余任务
+func (_f *_Fetcher) parallel(ctx context.Context, n int, fn func(ctx context.Context, i int) error) error {
+	if n == 1 {
+		return fn(ctx, 0)
+	}
+	g, ctx := errgroup.WithContext(ctx)
+	if _f.opt.concurrency > 0 {
+		g.SetLimit(_f.opt.concurrency)
+	}
+	for i := 0; i < n; i++ {
+		i := i
+		g.Go(func() error {
+			return fn(ctx, i)
+		})
+	}
+	return g.Wait()
+}
+
+// cacheMGet 按 maxCacheBatch 分批查询缓存，超时时间由 WithCacheReadTimeout 设置
+func (f *Fetcher) cacheMGet(ctx context.Context, keys []string) (_ map[string][]byte, err error) {
+	start := time.Now()
+	ctx, span := f.startSpan(ctx, spanCacheRead, Attr{Key: attrKeyCount, Value: len(keys)})
+	defer func() {
+		span.End(err)
+		f.observe(OpCacheRead, start, err)
+	}()
+	ctx, cancel := timeoutContext(ctx, f.opt.cacheReadTimeout)
+	defer cancel()
+	chunks := chunk(keys, f.opt.maxCacheBatch)
+	if len(chunks) == 1 {
+		return f.ca.cache.MGet(ctx, keys...)
+	}
+	ms := make([]map[string][]byte, len(chunks))
+	err = f.parallel(ctx, len(chunks), func(ctx context.Context, i int) error {
+		var err error
+		ms[i], err = f.ca.cache.MGet(ctx, chunks[i]...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return mergeMaps(ms), nil
+}
+
+// cacheMSet 按 maxCacheBatch 分批写入缓存，超时时间由 WithCacheWriteTimeout 设置
+func (f *Fetcher) cacheMSet(ctx context.Context, ttl *time.Duration, kvs []*cache.KV) (err error) {
+	start := time.Now()
+	ctx, span := f.startSpan(ctx, spanCacheWrite, Attr{Key: attrKeyCount, Value: len(kvs)})
+	defer func() {
+		span.End(err)
+		f.observe(OpCacheWrite, start, err)
+	}()
+	ctx, cancel := timeoutContext(ctx, f.opt.cacheWriteTimeout)
+	defer cancel()
+	chunks := chunk(kvs, f.opt.maxCacheBatch)
+	return f.parallel(ctx, len(chunks), func(ctx context.Context, i int) error {
+		return f.ca.cache.MSet(ctx, ttl, chunks[i]...)
+	})
+}
+
+// cacheHMGet 按 maxCacheBatch 分批查询 hash
+func (hf *HFetcher) cacheHMGet(ctx context.Context, key string, fields []string) (_ map[string][]byte, err error) {
+	start := time.Now()
+	ctx, span := hf.startSpan(ctx, spanCacheRead, Attr{Key: attrKeyCount, Value: len(fields)})
+	defer func() {
+		span.End(err)
+		hf.observe(OpCacheRead, start, err)
+	}()
+	ctx, cancel := timeoutContext(ctx, hf.opt.cacheReadTimeout)
+	defer cancel()
+	chunks := chunk(fields, hf.opt.maxCacheBatch)
+	if len(chunks) == 1 {
+		return hf.ca.hcache.HMGet(ctx, key, fields...)
+	}
+	ms := make([]map[string][]byte, len(chunks))
+	err = hf.parallel(ctx, len(chunks), func(ctx context.Context, i int) error {
+		var err error
+		ms[i], err = hf.ca.hcache.HMGet(ctx, key, chunks[i]...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return mergeMaps(ms), nil
+}
+
+// cacheHMSet 按 maxCacheBatch 分批写入 hash
+func (hf *HFetcher) cacheHMSet(ctx context.Context, key string, ttl *time.Duration, kvs []*cache.KV) (err error) {
+	start := time.Now()
+	ctx, span := hf.startSpan(ctx, spanCacheWrite, Attr{Key: attrKeyCount, Value: len(kvs)})
+	defer func() {
+		span.End(err)
+		hf.observe(OpCacheWrite, start, err)
+	}()
+	ctx, cancel := timeoutContext(ctx, hf.opt.cacheWriteTimeout)
+	defer cancel()
+	chunks := chunk(kvs, hf.opt.maxCacheBatch)
+	return hf.parallel(ctx, len(chunks), func(ctx context.Context, i int) error {
+		return hf.ca.hcache.HMSet(ctx, key, ttl, chunks[i]...)
+	})
+}
+
+// sourceChunks 按 maxSourceBatch 分批并发回源，合并各批结果
+func (_f *_Fetcher) sourceChunks(ctx context.Context, keys []string,
+	fn func(ctx context.Context, keys []string) (map[string]interface{}, map[string]error, error)) (
+	map[string]interface{}, map[string]error, error) {
+	chunks := chunk(keys, _f.opt.maxSourceBatch)
+	if len(chunks) == 1 {
+		return fn(ctx, keys)
+	}
+	ms := make([]map[string]interface{}, len(chunks))
+	errMs := make([]map[string]error, len(chunks))
+	err := _f.parallel(ctx, len(chunks), func(ctx context.Context, i int) error {
+		var err error
+		ms[i], errMs[i], err = fn(ctx, chunks[i])
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return mergeMaps(ms), mergeMaps(errMs), nil
+}
+
+func mergeMaps[V any](ms []map[string]V) map[string]V {
+	n := 0
+	for _, m := range ms {
+		n += len(m)
+	}
+	res := make(map[string]V, n)
+	for _, m := range ms {
+		for k, v := range m {
+			res[k] = v
+		}
+	}
+	return res
+}
@@ -0,0 +1,85 @@
+package cacheaside
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/erkesi/cacheaside/cache"
+	"github.com/erkesi/cacheaside/code"
+	"github.com/golang/mock/gomock"
+)
+
+func TestChunk(t *testing.T) {
+	if cs := chunk([]int{1, 2, 3, 4, 5}, 2); !reflect.DeepEqual(cs, [][]int{{1, 2}, {3, 4}, {5}}) {
+		t.Fatalf("unexpected chunks %v", cs)
+	}
+	if cs := chunk([]int{1, 2}, 0); !reflect.DeepEqual(cs, [][]int{{1, 2}}) {
+		t.Fatalf("unexpected chunks %v", cs)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mcache := cache.NewMockCacher(ctrl)
+	mcache.EXPECT().MGet(gomock.Any(), "ns$1", "ns$2").Return(map[string][]byte{"ns$1": []byte(`"1"`)}, nil)
+	mcache.EXPECT().MGet(gomock.Any(), "ns$3").Return(nil, nil)
+	mcache.EXPECT().MSet(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+
+	var mu sync.Mutex
+	var batches [][]string
+	ca := NewCacheAside(&code.Json{}, mcache, "ns")
+	caf := ca.Fetch(func(ctx context.Context, keys []string, extra ...interface{}) ([]interface{}, error) {
+		mu.Lock()
+		batches = append(batches, keys)
+		mu.Unlock()
+		var vals []interface{}
+		for _, key := range keys {
+			id := key[len("ns$"):]
+			vals = append(vals, &id)
+		}
+		return vals, nil
+	}, func(ctx context.Context, v interface{}, extra ...interface{}) (string, error) {
+		return *v.(*string), nil
+	}, WithTTL(time.Hour), WithMaxCacheBatch(2), WithMaxSourceBatch(1), WithConcurrency(2))
+
+	var res []*string
+	err := caf.MGet(context.Background(), []string{"1", "2", "3"}, &res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 3 || *res[0] != "1" || *res[1] != "2" || *res[2] != "3" {
+		t.Fatalf("unexpected res %v", res)
+	}
+	if len(batches) != 2 || len(batches[0]) != 1 || len(batches[1]) != 1 {
+		t.Fatalf("unexpected batches %v", batches)
+	}
+}
+
+func TestBatchUnlimitedConcurrency(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mcache := cache.NewMockCacher(ctrl)
+	mcache.EXPECT().MGet(gomock.Any(), "ns$1").Return(map[string][]byte{"ns$1": []byte(`"1"`)}, nil)
+	mcache.EXPECT().MGet(gomock.Any(), "ns$2").Return(map[string][]byte{"ns$2": []byte(`"2"`)}, nil)
+
+	ca := NewCacheAside(&code.Json{}, mcache, "ns")
+	caf := ca.Fetch(func(ctx context.Context, keys []string, extra ...interface{}) ([]interface{}, error) {
+		return nil, nil
+	}, func(ctx context.Context, v interface{}, extra ...interface{}) (string, error) {
+		return *v.(*string), nil
+	}, WithMaxCacheBatch(1), WithConcurrency(0))
+
+	var res []*string
+	if err := caf.MGet(context.Background(), []string{"1", "2"}, &res); err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 2 || *res[0] != "1" || *res[1] != "2" {
+		t.Fatalf("unexpected res %v", res)
+	}
+}
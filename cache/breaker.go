@@ -0,0 +1,356 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrBreakerOpen 熔断打开，请求未发送至缓存
+var ErrBreakerOpen = errors.New("cache: circuit breaker is open")
+
+// BreakerState 熔断状态
+type BreakerState int
+
+const (
+	// BreakerClosed 关闭，请求正常发送
+	BreakerClosed BreakerState = iota
+	// BreakerOpen 打开，请求直接失败
+	BreakerOpen
+	// BreakerHalfOpen 半开，允许少量探测请求，全部成功则关闭，任一失败则重新打开
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "Closed"
+	case BreakerOpen:
+		return "Open"
+	case BreakerHalfOpen:
+		return "HalfOpen"
+	}
+	return "Unknown"
+}
+
+type BreakerOption struct {
+	window        time.Duration
+	minRequests   int
+	errorRate     float64
+	slowThreshold time.Duration
+	slowRate      float64
+	openTimeout   time.Duration
+	probes        int
+	onStateChange func(from, to BreakerState)
+}
+
+type BreakerOptFn func(opt *BreakerOption)
+
+// WithBreakerWindow 统计窗口，默认 10s，窗口结束后重新统计
+func WithBreakerWindow(window time.Duration) BreakerOptFn {
+	return func(opt *BreakerOption) {
+		opt.window = window
+	}
+}
+
+// WithBreakerMinRequests 窗口内请求数达到 n 后才判断是否打开熔断，默认 20
+func WithBreakerMinRequests(n int) BreakerOptFn {
+	return func(opt *BreakerOption) {
+		opt.minRequests = n
+	}
+}
+
+// WithBreakerErrorRate 窗口内错误率达到 rate 时打开熔断，默认 0.5
+func WithBreakerErrorRate(rate float64) BreakerOptFn {
+	return func(opt *BreakerOption) {
+		opt.errorRate = rate
+	}
+}
+
+// WithBreakerSlowCall 耗时超过 threshold 的请求视为慢请求，窗口内慢请求比例达到 rate 时打开熔断，默认不开启
+func WithBreakerSlowCall(threshold time.Duration, rate float64) BreakerOptFn {
+	return func(opt *BreakerOption) {
+		opt.slowThreshold = threshold
+		opt.slowRate = rate
+	}
+}
+
+// WithBreakerOpenTimeout 熔断打开后经过 timeout 进入半开状态，默认 5s
+func WithBreakerOpenTimeout(timeout time.Duration) BreakerOptFn {
+	return func(opt *BreakerOption) {
+		opt.openTimeout = timeout
+	}
+}
+
+// WithBreakerProbes 半开状态允许的探测请求数，默认 1
+func WithBreakerProbes(n int) BreakerOptFn {
+	return func(opt *BreakerOption) {
+		opt.probes = n
+	}
+}
+
+// WithBreakerStateChange 熔断状态变化时回调，回调在持有锁时执行，不应阻塞
+func WithBreakerStateChange(onStateChange func(from, to BreakerState)) BreakerOptFn {
+	return func(opt *BreakerOption) {
+		opt.onStateChange = onStateChange
+	}
+}
+
+// Breaker 熔断器，可在 Cacher 与 HCacher 间共享
+type Breaker struct {
+	opt *BreakerOption
+
+	mu          sync.Mutex
+	state       BreakerState
+	generation  uint64
+	windowStart time.Time
+	requests    int
+	failures    int
+	slows       int
+	openedAt    time.Time
+	probing     int
+	probed      int
+}
+
+func NewBreaker(opts ...BreakerOptFn) *Breaker {
+	opt := &BreakerOption{
+		window:      10 * time.Second,
+		minRequests: 20,
+		errorRate:   0.5,
+		openTimeout: 5 * time.Second,
+		probes:      1,
+	}
+	for _, fn := range opts {
+		fn(opt)
+	}
+	return &Breaker{
+		opt:         opt,
+		windowStart: time.Now(),
+	}
+}
+
+// State 当前熔断状态
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance(time.Now())
+	return b.state
+}
+
+// Available 熔断未打开时可用
+func (b *Breaker) Available() bool {
+	return b.State() != BreakerOpen
+}
+
+// Do 熔断允许时执行 fn 并记录结果，否则返回 ErrBreakerOpen
+func (b *Breaker) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	generation, ok := b.allow()
+	if !ok {
+		return ErrBreakerOpen
+	}
+	start := time.Now()
+	err := fn(ctx)
+	b.done(generation, err, time.Since(start))
+	return err
+}
+
+func (b *Breaker) allow() (uint64, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance(time.Now())
+	switch b.state {
+	case BreakerOpen:
+		return 0, false
+	case BreakerHalfOpen:
+		if b.probing >= b.opt.probes {
+			return 0, false
+		}
+		b.probing++
+	}
+	return b.generation, true
+}
+
+func (b *Breaker) done(generation uint64, err error, cost time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	// 状态已变化，忽略之前发出的请求
+	if generation != b.generation {
+		return
+	}
+	// 调用方取消不代表缓存异常，不计入统计
+	if errors.Is(err, context.Canceled) {
+		if b.state == BreakerHalfOpen {
+			b.probing--
+		}
+		return
+	}
+	failed := err != nil
+	slow := b.opt.slowThreshold > 0 && cost >= b.opt.slowThreshold
+	now := time.Now()
+	switch b.state {
+	case BreakerHalfOpen:
+		if failed || slow {
+			b.setState(BreakerOpen, now)
+			return
+		}
+		b.probed++
+		if b.probed >= b.opt.probes {
+			b.setState(BreakerClosed, now)
+		}
+	case BreakerClosed:
+		b.advance(now)
+		b.requests++
+		if failed {
+			b.failures++
+		}
+		if slow {
+			b.slows++
+		}
+		if b.requests < b.opt.minRequests {
+			return
+		}
+		if float64(b.failures) >= b.opt.errorRate*float64(b.requests) ||
+			(b.opt.slowThreshold > 0 && float64(b.slows) >= b.opt.slowRate*float64(b.requests)) {
+			b.setState(BreakerOpen, now)
+		}
+	}
+}
+
+// advance 打开超时后进入半开状态，关闭状态下窗口结束后重新统计
+func (b *Breaker) advance(now time.Time) {
+	switch b.state {
+	case BreakerOpen:
+		if now.Sub(b.openedAt) >= b.opt.openTimeout {
+			b.setState(BreakerHalfOpen, now)
+		}
+	case BreakerClosed:
+		if now.Sub(b.windowStart) >= b.opt.window {
+			b.windowStart = now
+			b.requests, b.failures, b.slows = 0, 0, 0
+		}
+	}
+}
+
+func (b *Breaker) setState(state BreakerState, now time.Time) {
+	from := b.state
+	b.state = state
+	b.generation++
+	b.windowStart = now
+	b.requests, b.failures, b.slows = 0, 0, 0
+	b.probing, b.probed = 0, 0
+	if state == BreakerOpen {
+		b.openedAt = now
+	}
+	if b.opt.onStateChange != nil {
+		b.opt.onStateChange(from, state)
+	}
+}
+
+// BreakerCacher 熔断 Cacher，熔断打开时请求直接返回 ErrBreakerOpen
+type BreakerCacher struct {
+	cacher  Cacher
+	breaker *Breaker
+}
+
+func NewBreakerCacher(cacher Cacher, breaker *Breaker) *BreakerCacher {
+	return &BreakerCacher{
+		cacher:  cacher,
+		breaker: breaker,
+	}
+}
+
+func (c *BreakerCacher) MSet(ctx context.Context, ttl *time.Duration, kvs ...*KV) error {
+	return c.breaker.Do(ctx, func(ctx context.Context) error {
+		return c.cacher.MSet(ctx, ttl, kvs...)
+	})
+}
+
+func (c *BreakerCacher) MGet(ctx context.Context, keys ...string) (map[string][]byte, error) {
+	var res map[string][]byte
+	err := c.breaker.Do(ctx, func(ctx context.Context) error {
+		var err error
+		res, err = c.cacher.MGet(ctx, keys...)
+		return err
+	})
+	return res, err
+}
+
+func (c *BreakerCacher) MDel(ctx context.Context, keys ...string) error {
+	return c.breaker.Do(ctx, func(ctx context.Context) error {
+		return c.cacher.MDel(ctx, keys...)
+	})
+}
+
+// MGetTTL 被包装缓存实现 TTLCacher 时透传剩余过期时间，否则等同于 MGet
+func (c *BreakerCacher) MGetTTL(ctx context.Context, keys ...string) (map[string][]byte, map[string]time.Duration, error) {
+	var res map[string][]byte
+	var ttls map[string]time.Duration
+	err := c.breaker.Do(ctx, func(ctx context.Context) error {
+		var err error
+		res, ttls, err = mGetTTL(ctx, c.cacher, keys...)
+		return err
+	})
+	return res, ttls, err
+}
+
+func (c *BreakerCacher) Available() bool {
+	return c.breaker.Available()
+}
+
+// BreakerHCacher 熔断 HCacher，熔断打开时请求直接返回 ErrBreakerOpen
+type BreakerHCacher struct {
+	hcacher HCacher
+	breaker *Breaker
+}
+
+func NewBreakerHCacher(hcacher HCacher, breaker *Breaker) *BreakerHCacher {
+	return &BreakerHCacher{
+		hcacher: hcacher,
+		breaker: breaker,
+	}
+}
+
+func (c *BreakerHCacher) HMSet(ctx context.Context, key string, ttl *time.Duration, kvs ...*KV) error {
+	return c.breaker.Do(ctx, func(ctx context.Context) error {
+		return c.hcacher.HMSet(ctx, key, ttl, kvs...)
+	})
+}
+
+func (c *BreakerHCacher) HMGet(ctx context.Context, key string, fields ...string) (map[string][]byte, error) {
+	var res map[string][]byte
+	err := c.breaker.Do(ctx, func(ctx context.Context) error {
+		var err error
+		res, err = c.hcacher.HMGet(ctx, key, fields...)
+		return err
+	})
+	return res, err
+}
+
+func (c *BreakerHCacher) HDel(ctx context.Context, key string) error {
+	return c.breaker.Do(ctx, func(ctx context.Context) error {
+		return c.hcacher.HDel(ctx, key)
+	})
+}
+
+func (c *BreakerHCacher) HMDel(ctx context.Context, key string, fields ...string) error {
+	return c.breaker.Do(ctx, func(ctx context.Context) error {
+		return c.hcacher.HMDel(ctx, key, fields...)
+	})
+}
+
+// HMGetTTL 被包装缓存实现 HTTLCacher 时透传剩余过期时间，否则等同于 HMGet
+func (c *BreakerHCacher) HMGetTTL(ctx context.Context, key string, fields ...string) (map[string][]byte, *time.Duration, error) {
+	var res map[string][]byte
+	var ttl *time.Duration
+	err := c.breaker.Do(ctx, func(ctx context.Context) error {
+		var err error
+		res, ttl, err = hmGetTTL(ctx, c.hcacher, key, fields...)
+		return err
+	})
+	return res, ttl, err
+}
+
+func (c *BreakerHCacher) Available() bool {
+	return c.breaker.Available()
+}
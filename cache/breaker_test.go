@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+)
+
+func TestBreakerCacher(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cacheErr := errors.New("timeout")
+	mcache := NewMockCacher(ctrl)
+	gomock.InOrder(
+		mcache.EXPECT().MGet(gomock.Any(), "k").Return(nil, cacheErr).Times(2),
+		mcache.EXPECT().MGet(gomock.Any(), "k").Return(map[string][]byte{"k": []byte("v")}, nil),
+	)
+
+	var changes []BreakerState
+	b := NewBreaker(WithBreakerMinRequests(2), WithBreakerErrorRate(0.5),
+		WithBreakerOpenTimeout(20*time.Millisecond), WithBreakerStateChange(func(from, to BreakerState) {
+			changes = append(changes, to)
+		}))
+	c := NewBreakerCacher(mcache, b)
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if _, err := c.MGet(ctx, "k"); !errors.Is(err, cacheErr) {
+			t.Fatalf("unexpected err %v", err)
+		}
+	}
+	if c.Available() || b.State() != BreakerOpen {
+		t.Fatalf("unexpected state %v", b.State())
+	}
+	if _, err := c.MGet(ctx, "k"); !errors.Is(err, ErrBreakerOpen) {
+		t.Fatalf("unexpected err %v", err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if b.State() != BreakerHalfOpen {
+		t.Fatalf("unexpected state %v", b.State())
+	}
+	if res, err := c.MGet(ctx, "k"); err != nil || string(res["k"]) != "v" {
+		t.Fatalf("unexpected res %v, err %v", res, err)
+	}
+	if b.State() != BreakerClosed {
+		t.Fatalf("unexpected state %v", b.State())
+	}
+	if len(changes) != 3 || changes[0] != BreakerOpen || changes[1] != BreakerHalfOpen || changes[2] != BreakerClosed {
+		t.Fatalf("unexpected changes %v", changes)
+	}
+}
+
+func TestBreakerSlowCall(t *testing.T) {
+	b := NewBreaker(WithBreakerMinRequests(1), WithBreakerSlowCall(10*time.Millisecond, 1))
+	err := b.Do(context.Background(), func(ctx context.Context) error {
+		time.Sleep(20 * time.Millisecond)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.State() != BreakerOpen {
+		t.Fatalf("unexpected state %v", b.State())
+	}
+}
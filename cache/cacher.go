@@ -9,6 +9,8 @@ type KV struct {
 	Key  string
 	Val  interface{}
 	Data []byte
+	// TTL 单独的过期时间，为空时使用 MSet 的 ttl；hash 按 key 设置过期时间，HMSet 忽略该字段
+	TTL *time.Duration
 }
 
 // HCacher hash
@@ -25,3 +27,51 @@ type Cacher interface {
 	MGet(ctx context.Context, keys ...string) (map[string][]byte, error)
 	MDel(ctx context.Context, keys ...string) error
 }
+
+// TTLCacher 查询时同时返回 key 的剩余过期时间，不过期的 key 不在 ttls 中
+type TTLCacher interface {
+	MGetTTL(ctx context.Context, keys ...string) (key2Data map[string][]byte, ttls map[string]time.Duration, err error)
+}
+
+// HTTLCacher 查询时同时返回 hash key 的剩余过期时间，不过期时 ttl 为空
+type HTTLCacher interface {
+	HMGetTTL(ctx context.Context, key string, fields ...string) (field2Data map[string][]byte, ttl *time.Duration, err error)
+}
+
+// mGetTTL cacher 实现 TTLCacher 时同时返回剩余过期时间，否则 ttls 为空
+func mGetTTL(ctx context.Context, cacher Cacher, keys ...string) (map[string][]byte, map[string]time.Duration, error) {
+	if tc, ok := cacher.(TTLCacher); ok {
+		return tc.MGetTTL(ctx, keys...)
+	}
+	res, err := cacher.MGet(ctx, keys...)
+	return res, nil, err
+}
+
+// hmGetTTL hcacher 实现 HTTLCacher 时同时返回剩余过期时间，否则 ttl 为空
+func hmGetTTL(ctx context.Context, hcacher HCacher, key string, fields ...string) (map[string][]byte, *time.Duration, error) {
+	if tc, ok := hcacher.(HTTLCacher); ok {
+		return tc.HMGetTTL(ctx, key, fields...)
+	}
+	res, err := hcacher.HMGet(ctx, key, fields...)
+	return res, nil, err
+}
+
+// Counter 计数器，用于 namespace 版本号
+type Counter interface {
+	// Load 读取计数，key 不存在时返回 0
+	Load(ctx context.Context, key string) (int64, error)
+	Incr(ctx context.Context, key string) (int64, error)
+}
+
+// Locker 分布式锁，用于跨进程回源互斥
+type Locker interface {
+	// MLock 使用 token 对 keys 加锁，返回加锁成功的 keys
+	MLock(ctx context.Context, token string, ttl time.Duration, keys ...string) ([]string, error)
+	// MUnlock 释放 token 持有的 keys 的锁
+	MUnlock(ctx context.Context, token string, keys ...string) error
+}
+
+// Availabler 缓存可用性，Cacher/HCacher 实现该接口且不可用（如熔断打开）时，回源结果不再回写缓存，查询直接回源
+type Availabler interface {
+	Available() bool
+}
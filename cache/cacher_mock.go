@@ -187,3 +187,243 @@ func (mr *MockCacherMockRecorder) MSet(ctx, ttl interface{}, kvs ...interface{})
 	varargs := append([]interface{}{ctx, ttl}, kvs...)
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MSet", reflect.TypeOf((*MockCacher)(nil).MSet), varargs...)
 }
+
+// MockTTLCacher is a mock of TTLCacher interface.
+type MockTTLCacher struct {
+	ctrl     *gomock.Controller
+	recorder *MockTTLCacherMockRecorder
+}
+
+// MockTTLCacherMockRecorder is the mock recorder for MockTTLCacher.
+type MockTTLCacherMockRecorder struct {
+	mock *MockTTLCacher
+}
+
+// NewMockTTLCacher creates a new mock instance.
+func NewMockTTLCacher(ctrl *gomock.Controller) *MockTTLCacher {
+	mock := &MockTTLCacher{ctrl: ctrl}
+	mock.recorder = &MockTTLCacherMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTTLCacher) EXPECT() *MockTTLCacherMockRecorder {
+	return m.recorder
+}
+
+// MGetTTL mocks base method.
+func (m *MockTTLCacher) MGetTTL(ctx context.Context, keys ...string) (map[string][]byte, map[string]time.Duration, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx}
+	for _, a := range keys {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "MGetTTL", varargs...)
+	ret0, _ := ret[0].(map[string][]byte)
+	ret1, _ := ret[1].(map[string]time.Duration)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// MGetTTL indicates an expected call of MGetTTL.
+func (mr *MockTTLCacherMockRecorder) MGetTTL(ctx interface{}, keys ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx}, keys...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MGetTTL", reflect.TypeOf((*MockTTLCacher)(nil).MGetTTL), varargs...)
+}
+
+// MockHTTLCacher is a mock of HTTLCacher interface.
+type MockHTTLCacher struct {
+	ctrl     *gomock.Controller
+	recorder *MockHTTLCacherMockRecorder
+}
+
+// MockHTTLCacherMockRecorder is the mock recorder for MockHTTLCacher.
+type MockHTTLCacherMockRecorder struct {
+	mock *MockHTTLCacher
+}
+
+// NewMockHTTLCacher creates a new mock instance.
+func NewMockHTTLCacher(ctrl *gomock.Controller) *MockHTTLCacher {
+	mock := &MockHTTLCacher{ctrl: ctrl}
+	mock.recorder = &MockHTTLCacherMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockHTTLCacher) EXPECT() *MockHTTLCacherMockRecorder {
+	return m.recorder
+}
+
+// HMGetTTL mocks base method.
+func (m *MockHTTLCacher) HMGetTTL(ctx context.Context, key string, fields ...string) (map[string][]byte, *time.Duration, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, key}
+	for _, a := range fields {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "HMGetTTL", varargs...)
+	ret0, _ := ret[0].(map[string][]byte)
+	ret1, _ := ret[1].(*time.Duration)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// HMGetTTL indicates an expected call of HMGetTTL.
+func (mr *MockHTTLCacherMockRecorder) HMGetTTL(ctx, key interface{}, fields ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, key}, fields...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HMGetTTL", reflect.TypeOf((*MockHTTLCacher)(nil).HMGetTTL), varargs...)
+}
+
+// MockCounter is a mock of Counter interface.
+type MockCounter struct {
+	ctrl     *gomock.Controller
+	recorder *MockCounterMockRecorder
+}
+
+// MockCounterMockRecorder is the mock recorder for MockCounter.
+type MockCounterMockRecorder struct {
+	mock *MockCounter
+}
+
+// NewMockCounter creates a new mock instance.
+func NewMockCounter(ctrl *gomock.Controller) *MockCounter {
+	mock := &MockCounter{ctrl: ctrl}
+	mock.recorder = &MockCounterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCounter) EXPECT() *MockCounterMockRecorder {
+	return m.recorder
+}
+
+// Incr mocks base method.
+func (m *MockCounter) Incr(ctx context.Context, key string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Incr", ctx, key)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Incr indicates an expected call of Incr.
+func (mr *MockCounterMockRecorder) Incr(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Incr", reflect.TypeOf((*MockCounter)(nil).Incr), ctx, key)
+}
+
+// Load mocks base method.
+func (m *MockCounter) Load(ctx context.Context, key string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Load", ctx, key)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Load indicates an expected call of Load.
+func (mr *MockCounterMockRecorder) Load(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Load", reflect.TypeOf((*MockCounter)(nil).Load), ctx, key)
+}
+
+// MockLocker is a mock of Locker interface.
+type MockLocker struct {
+	ctrl     *gomock.Controller
+	recorder *MockLockerMockRecorder
+}
+
+// MockLockerMockRecorder is the mock recorder for MockLocker.
+type MockLockerMockRecorder struct {
+	mock *MockLocker
+}
+
+// NewMockLocker creates a new mock instance.
+func NewMockLocker(ctrl *gomock.Controller) *MockLocker {
+	mock := &MockLocker{ctrl: ctrl}
+	mock.recorder = &MockLockerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLocker) EXPECT() *MockLockerMockRecorder {
+	return m.recorder
+}
+
+// MLock mocks base method.
+func (m *MockLocker) MLock(ctx context.Context, token string, ttl time.Duration, keys ...string) ([]string, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, token, ttl}
+	for _, a := range keys {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "MLock", varargs...)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MLock indicates an expected call of MLock.
+func (mr *MockLockerMockRecorder) MLock(ctx, token, ttl interface{}, keys ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, token, ttl}, keys...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MLock", reflect.TypeOf((*MockLocker)(nil).MLock), varargs...)
+}
+
+// MUnlock mocks base method.
+func (m *MockLocker) MUnlock(ctx context.Context, token string, keys ...string) error {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, token}
+	for _, a := range keys {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "MUnlock", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MUnlock indicates an expected call of MUnlock.
+func (mr *MockLockerMockRecorder) MUnlock(ctx, token interface{}, keys ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, token}, keys...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MUnlock", reflect.TypeOf((*MockLocker)(nil).MUnlock), varargs...)
+}
+
+// MockAvailabler is a mock of Availabler interface.
+type MockAvailabler struct {
+	ctrl     *gomock.Controller
+	recorder *MockAvailablerMockRecorder
+}
+
+// MockAvailablerMockRecorder is the mock recorder for MockAvailabler.
+type MockAvailablerMockRecorder struct {
+	mock *MockAvailabler
+}
+
+// NewMockAvailabler creates a new mock instance.
+func NewMockAvailabler(ctrl *gomock.Controller) *MockAvailabler {
+	mock := &MockAvailabler{ctrl: ctrl}
+	mock.recorder = &MockAvailablerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAvailabler) EXPECT() *MockAvailablerMockRecorder {
+	return m.recorder
+}
+
+// Available mocks base method.
+func (m *MockAvailabler) Available() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Available")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// Available indicates an expected call of Available.
+func (mr *MockAvailablerMockRecorder) Available() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Available", reflect.TypeOf((*MockAvailabler)(nil).Available))
+}
@@ -0,0 +1,175 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+type MSetHandler func(ctx context.Context, ttl *time.Duration, kvs ...*KV) error
+
+type MGetHandler func(ctx context.Context, keys ...string) (map[string][]byte, error)
+
+type MDelHandler func(ctx context.Context, keys ...string) error
+
+type HMSetHandler func(ctx context.Context, key string, ttl *time.Duration, kvs ...*KV) error
+
+type HMGetHandler func(ctx context.Context, key string, fields ...string) (map[string][]byte, error)
+
+type HDelHandler func(ctx context.Context, key string) error
+
+type HMDelHandler func(ctx context.Context, key string, fields ...string) error
+
+// Middleware 缓存中间件，按操作拦截，调用 next 继续执行后续中间件与被包装的缓存；为空的操作不拦截
+type Middleware struct {
+	MSet  func(next MSetHandler) MSetHandler
+	MGet  func(next MGetHandler) MGetHandler
+	MDel  func(next MDelHandler) MDelHandler
+	HMSet func(next HMSetHandler) HMSetHandler
+	HMGet func(next HMGetHandler) HMGetHandler
+	HDel  func(next HDelHandler) HDelHandler
+	HMDel func(next HMDelHandler) HMDelHandler
+}
+
+// ttlSink 经 ctx 传递，MGetTTL/HMGetTTL 经过 MGet/HMGet 中间件后由最内层写回剩余过期时间
+type ttlSink struct{}
+
+type httlSink struct{}
+
+// ChainCacher 经过中间件的 Cacher，MGetTTL 经过 MGet 中间件
+type ChainCacher struct {
+	cacher Cacher
+	mSet   MSetHandler
+	mGet   MGetHandler
+	mDel   MDelHandler
+}
+
+// Chain 使用中间件包装 cacher，第一个中间件在最外层
+func Chain(cacher Cacher, mws ...Middleware) *ChainCacher {
+	c := &ChainCacher{
+		cacher: cacher,
+		mSet:   cacher.MSet,
+		mDel:   cacher.MDel,
+	}
+	c.mGet = c.get
+	for i := len(mws) - 1; i >= 0; i-- {
+		if mws[i].MSet != nil {
+			c.mSet = mws[i].MSet(c.mSet)
+		}
+		if mws[i].MGet != nil {
+			c.mGet = mws[i].MGet(c.mGet)
+		}
+		if mws[i].MDel != nil {
+			c.mDel = mws[i].MDel(c.mDel)
+		}
+	}
+	return c
+}
+
+func (c *ChainCacher) MSet(ctx context.Context, ttl *time.Duration, kvs ...*KV) error {
+	return c.mSet(ctx, ttl, kvs...)
+}
+
+func (c *ChainCacher) MGet(ctx context.Context, keys ...string) (map[string][]byte, error) {
+	return c.mGet(ctx, keys...)
+}
+
+func (c *ChainCacher) MDel(ctx context.Context, keys ...string) error {
+	return c.mDel(ctx, keys...)
+}
+
+// MGetTTL 被包装缓存实现 TTLCacher 时透传剩余过期时间，否则等同于 MGet
+func (c *ChainCacher) MGetTTL(ctx context.Context, keys ...string) (map[string][]byte, map[string]time.Duration, error) {
+	var ttls map[string]time.Duration
+	res, err := c.mGet(context.WithValue(ctx, ttlSink{}, &ttls), keys...)
+	return res, ttls, err
+}
+
+func (c *ChainCacher) get(ctx context.Context, keys ...string) (map[string][]byte, error) {
+	sink, ok := ctx.Value(ttlSink{}).(*map[string]time.Duration)
+	if !ok {
+		return c.cacher.MGet(ctx, keys...)
+	}
+	res, ttls, err := mGetTTL(ctx, c.cacher, keys...)
+	*sink = ttls
+	return res, err
+}
+
+// Available 透传被包装缓存的可用性
+func (c *ChainCacher) Available() bool {
+	a, ok := c.cacher.(Availabler)
+	return !ok || a.Available()
+}
+
+// ChainHCacher 经过中间件的 HCacher，HMGetTTL 经过 HMGet 中间件
+type ChainHCacher struct {
+	hcacher HCacher
+	hmSet   HMSetHandler
+	hmGet   HMGetHandler
+	hDel    HDelHandler
+	hmDel   HMDelHandler
+}
+
+// HChain 使用中间件包装 hcacher，第一个中间件在最外层
+func HChain(hcacher HCacher, mws ...Middleware) *ChainHCacher {
+	c := &ChainHCacher{
+		hcacher: hcacher,
+		hmSet:   hcacher.HMSet,
+		hDel:    hcacher.HDel,
+		hmDel:   hcacher.HMDel,
+	}
+	c.hmGet = c.get
+	for i := len(mws) - 1; i >= 0; i-- {
+		if mws[i].HMSet != nil {
+			c.hmSet = mws[i].HMSet(c.hmSet)
+		}
+		if mws[i].HMGet != nil {
+			c.hmGet = mws[i].HMGet(c.hmGet)
+		}
+		if mws[i].HDel != nil {
+			c.hDel = mws[i].HDel(c.hDel)
+		}
+		if mws[i].HMDel != nil {
+			c.hmDel = mws[i].HMDel(c.hmDel)
+		}
+	}
+	return c
+}
+
+func (c *ChainHCacher) HMSet(ctx context.Context, key string, ttl *time.Duration, kvs ...*KV) error {
+	return c.hmSet(ctx, key, ttl, kvs...)
+}
+
+func (c *ChainHCacher) HMGet(ctx context.Context, key string, fields ...string) (map[string][]byte, error) {
+	return c.hmGet(ctx, key, fields...)
+}
+
+func (c *ChainHCacher) HDel(ctx context.Context, key string) error {
+	return c.hDel(ctx, key)
+}
+
+func (c *ChainHCacher) HMDel(ctx context.Context, key string, fields ...string) error {
+	return c.hmDel(ctx, key, fields...)
+}
+
+// HMGetTTL 被包装缓存实现 HTTLCacher 时透传剩余过期时间，否则等同于 HMGet
+func (c *ChainHCacher) HMGetTTL(ctx context.Context, key string, fields ...string) (map[string][]byte, *time.Duration, error) {
+	var ttl *time.Duration
+	res, err := c.hmGet(context.WithValue(ctx, httlSink{}, &ttl), key, fields...)
+	return res, ttl, err
+}
+
+func (c *ChainHCacher) get(ctx context.Context, key string, fields ...string) (map[string][]byte, error) {
+	sink, ok := ctx.Value(httlSink{}).(**time.Duration)
+	if !ok {
+		return c.hcacher.HMGet(ctx, key, fields...)
+	}
+	res, ttl, err := hmGetTTL(ctx, c.hcacher, key, fields...)
+	*sink = ttl
+	return res, err
+}
+
+// Available 透传被包装缓存的可用性
+func (c *ChainHCacher) Available() bool {
+	a, ok := c.hcacher.(Availabler)
+	return !ok || a.Available()
+}
@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+)
+
+func TestChain(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mcache := NewMockCacher(ctrl)
+	mcache.EXPECT().MGet(gomock.Any(), "app:k1", "app:k2").Return(map[string][]byte{"app:k1": []byte("v1")}, nil)
+	mcache.EXPECT().MDel(gomock.Any(), "k1").Return(nil)
+
+	var calls []string
+	trace := func(name string) Middleware {
+		return Middleware{
+			MGet: func(next MGetHandler) MGetHandler {
+				return func(ctx context.Context, keys ...string) (map[string][]byte, error) {
+					calls = append(calls, name)
+					return next(ctx, keys...)
+				}
+			},
+		}
+	}
+	prefix := Middleware{
+		MGet: func(next MGetHandler) MGetHandler {
+			return func(ctx context.Context, keys ...string) (map[string][]byte, error) {
+				prefixed := make([]string, 0, len(keys))
+				for _, key := range keys {
+					prefixed = append(prefixed, "app:"+key)
+				}
+				res, err := next(ctx, prefixed...)
+				if err != nil {
+					return nil, err
+				}
+				m := make(map[string][]byte, len(res))
+				for key, data := range res {
+					m[strings.TrimPrefix(key, "app:")] = data
+				}
+				return m, nil
+			}
+		},
+	}
+
+	c := Chain(mcache, trace("outer"), prefix, trace("inner"))
+	res, err := c.MGet(context.Background(), "k1", "k2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(res["k1"]) != "v1" || len(res) != 1 {
+		t.Fatalf("unexpected res %v", res)
+	}
+	if !reflect.DeepEqual(calls, []string{"outer", "inner"}) {
+		t.Fatalf("unexpected calls %v", calls)
+	}
+	if err = c.MDel(context.Background(), "k1"); err != nil {
+		t.Fatal(err)
+	}
+	if !c.Available() {
+		t.Fatal("should be available")
+	}
+}
+
+func TestHChain(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mcache := NewMockHCacher(ctrl)
+	mcache.EXPECT().HMDel(gomock.Any(), "h", "f1").Return(nil)
+	mcache.EXPECT().HDel(gomock.Any(), "h").Return(nil)
+
+	var deleted []string
+	c := HChain(mcache, Middleware{
+		HMDel: func(next HMDelHandler) HMDelHandler {
+			return func(ctx context.Context, key string, fields ...string) error {
+				deleted = append(deleted, fields...)
+				return next(ctx, key, fields...)
+			}
+		},
+	})
+	if err := c.HMDel(context.Background(), "h", "f1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.HDel(context.Background(), "h"); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(deleted, []string{"f1"}) {
+		t.Fatalf("unexpected deleted %v", deleted)
+	}
+}
@@ -2,4 +2,4 @@ module github.com/erkesi/cacheaside/cache
 
 go 1.16
 
-require github.com/golang/mock v1.6.0 // indirect
+require github.com/golang/mock v1.6.0
@@ -0,0 +1,368 @@
+package memory
+
+import (
+	"container/list"
+	"context"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/erkesi/cacheaside/cache"
+)
+
+const defaultShards = 16
+
+// EvictReason 淘汰原因
+type EvictReason int
+
+const (
+	// EvictExpired 过期
+	EvictExpired EvictReason = iota
+	// EvictCapacity 超过最大条目数或字节数，按 LRU 淘汰
+	EvictCapacity
+)
+
+func (r EvictReason) String() string {
+	switch r {
+	case EvictExpired:
+		return "Expired"
+	case EvictCapacity:
+		return "Capacity"
+	}
+	return "Unknown"
+}
+
+type Option struct {
+	shards     int
+	maxEntries int
+	maxBytes   int
+	onEvict    func(key string, reason EvictReason)
+	now        func() time.Time
+}
+
+type OptFn func(opt *Option)
+
+// WithShards 分片数，每个分片单独加锁并单独按 LRU 淘汰，默认 16
+func WithShards(n int) OptFn {
+	return func(opt *Option) {
+		opt.shards = n
+	}
+}
+
+// WithMaxEntries 最大条目数（hash 按 key 计一条），平均分配到各分片，默认不限制
+func WithMaxEntries(n int) OptFn {
+	return func(opt *Option) {
+		opt.maxEntries = n
+	}
+}
+
+// WithMaxBytes 最大字节数（key、field 与数据长度之和），平均分配到各分片，默认不限制
+func WithMaxBytes(n int) OptFn {
+	return func(opt *Option) {
+		opt.maxBytes = n
+	}
+}
+
+// WithEvict 条目因过期或容量被淘汰时回调（主动删除不回调），回调在分片锁内执行，不应再访问缓存
+func WithEvict(fn func(key string, reason EvictReason)) OptFn {
+	return func(opt *Option) {
+		opt.onEvict = fn
+	}
+}
+
+// WithClock 设置时钟，用于测试
+func WithClock(now func() time.Time) OptFn {
+	return func(opt *Option) {
+		opt.now = now
+	}
+}
+
+// Cache 进程内缓存，实现了 cache.Cacher 与 cache.HCacher，string 与 hash 共用 key 空间；
+// 查询返回的数据不可修改
+type Cache struct {
+	opt    *Option
+	shards []*shard
+}
+
+var (
+	_ cache.Cacher  = (*Cache)(nil)
+	_ cache.HCacher = (*Cache)(nil)
+)
+
+type shard struct {
+	mu         sync.Mutex
+	opt        *Option
+	maxEntries int
+	maxBytes   int
+	bytes      int
+	items      map[string]*list.Element
+	lru        *list.List
+}
+
+type entry struct {
+	key    string
+	data   []byte
+	fields map[string][]byte
+	expire time.Time
+	size   int
+}
+
+func New(opts ...OptFn) *Cache {
+	opt := &Option{
+		shards: defaultShards,
+		now:    time.Now,
+	}
+	for _, fn := range opts {
+		fn(opt)
+	}
+	if opt.shards <= 0 {
+		opt.shards = 1
+	}
+	c := &Cache{
+		opt:    opt,
+		shards: make([]*shard, opt.shards),
+	}
+	for i := range c.shards {
+		c.shards[i] = &shard{
+			opt:        opt,
+			maxEntries: perShard(opt.maxEntries, opt.shards),
+			maxBytes:   perShard(opt.maxBytes, opt.shards),
+			items:      make(map[string]*list.Element),
+			lru:        list.New(),
+		}
+	}
+	return c
+}
+
+func perShard(n, shards int) int {
+	if n <= 0 {
+		return 0
+	}
+	return (n + shards - 1) / shards
+}
+
+func (c *Cache) MSet(ctx context.Context, ttl *time.Duration, kvs ...*cache.KV) error {
+	now := c.opt.now()
+	for _, kv := range kvs {
+		kvTTL := ttl
+		if kv.TTL != nil {
+			kvTTL = kv.TTL
+		}
+		s := c.shard(kv.Key)
+		s.mu.Lock()
+		s.set(&entry{
+			key:    kv.Key,
+			data:   copyBytes(kv.Data),
+			expire: expireAt(now, kvTTL),
+		})
+		s.mu.Unlock()
+	}
+	return nil
+}
+
+func (c *Cache) MGet(ctx context.Context, keys ...string) (map[string][]byte, error) {
+	now := c.opt.now()
+	key2Data := make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		s := c.shard(key)
+		s.mu.Lock()
+		if e := s.get(key, now); e != nil && e.fields == nil {
+			key2Data[key] = e.data
+		}
+		s.mu.Unlock()
+	}
+	return key2Data, nil
+}
+
+func (c *Cache) MDel(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		s := c.shard(key)
+		s.mu.Lock()
+		s.remove(key)
+		s.mu.Unlock()
+	}
+	return nil
+}
+
+// HMSet ttl 为空时保留 key 原有的过期时间
+func (c *Cache) HMSet(ctx context.Context, key string, ttl *time.Duration, kvs ...*cache.KV) error {
+	if len(kvs) == 0 {
+		return nil
+	}
+	now := c.opt.now()
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := &entry{
+		key:    key,
+		fields: make(map[string][]byte, len(kvs)),
+		expire: expireAt(now, ttl),
+	}
+	if old := s.get(key, now); old != nil && old.fields != nil {
+		for field, data := range old.fields {
+			e.fields[field] = data
+		}
+		if ttl == nil {
+			e.expire = old.expire
+		}
+	}
+	for _, kv := range kvs {
+		e.fields[kv.Key] = copyBytes(kv.Data)
+	}
+	s.set(e)
+	return nil
+}
+
+func (c *Cache) HMGet(ctx context.Context, key string, fields ...string) (map[string][]byte, error) {
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	field2Data := make(map[string][]byte, len(fields))
+	e := s.get(key, c.opt.now())
+	if e == nil || e.fields == nil {
+		return field2Data, nil
+	}
+	for _, field := range fields {
+		if data, ok := e.fields[field]; ok {
+			field2Data[field] = data
+		}
+	}
+	return field2Data, nil
+}
+
+func (c *Cache) HDel(ctx context.Context, key string) error {
+	s := c.shard(key)
+	s.mu.Lock()
+	s.remove(key)
+	s.mu.Unlock()
+	return nil
+}
+
+func (c *Cache) HMDel(ctx context.Context, key string, fields ...string) error {
+	now := c.opt.now()
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := s.get(key, now)
+	if e == nil || e.fields == nil {
+		return nil
+	}
+	ne := &entry{
+		key:    key,
+		fields: make(map[string][]byte, len(e.fields)),
+		expire: e.expire,
+	}
+	for field, data := range e.fields {
+		ne.fields[field] = data
+	}
+	for _, field := range fields {
+		delete(ne.fields, field)
+	}
+	if len(ne.fields) == 0 {
+		s.remove(key)
+		return nil
+	}
+	s.set(ne)
+	return nil
+}
+
+// Clear 清空所有条目，不回调 WithEvict
+func (c *Cache) Clear() {
+	for _, s := range c.shards {
+		s.mu.Lock()
+		s.items = make(map[string]*list.Element)
+		s.lru.Init()
+		s.bytes = 0
+		s.mu.Unlock()
+	}
+}
+
+// Len 当前条目数（含已过期未清理的条目）
+func (c *Cache) Len() int {
+	n := 0
+	for _, s := range c.shards {
+		s.mu.Lock()
+		n += s.lru.Len()
+		s.mu.Unlock()
+	}
+	return n
+}
+
+func (c *Cache) shard(key string) *shard {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return c.shards[h.Sum32()%uint32(len(c.shards))]
+}
+
+// get 查询未过期的条目并移至 LRU 头部，已过期的条目直接淘汰
+func (s *shard) get(key string, now time.Time) *entry {
+	elem, ok := s.items[key]
+	if !ok {
+		return nil
+	}
+	e := elem.Value.(*entry)
+	if !e.expire.IsZero() && !now.Before(e.expire) {
+		s.evict(elem, EvictExpired)
+		return nil
+	}
+	s.lru.MoveToFront(elem)
+	return e
+}
+
+// set 写入条目，超过容量时从 LRU 尾部淘汰
+func (s *shard) set(e *entry) {
+	e.size = e.sizeOf()
+	if elem, ok := s.items[e.key]; ok {
+		s.bytes -= elem.Value.(*entry).size
+		elem.Value = e
+		s.lru.MoveToFront(elem)
+	} else {
+		s.items[e.key] = s.lru.PushFront(e)
+	}
+	s.bytes += e.size
+	for s.lru.Len() > 1 && ((s.maxEntries > 0 && s.lru.Len() > s.maxEntries) ||
+		(s.maxBytes > 0 && s.bytes > s.maxBytes)) {
+		s.evict(s.lru.Back(), EvictCapacity)
+	}
+}
+
+func (s *shard) remove(key string) {
+	if elem, ok := s.items[key]; ok {
+		s.delete(elem)
+	}
+}
+
+func (s *shard) evict(elem *list.Element, reason EvictReason) {
+	s.delete(elem)
+	if s.opt.onEvict != nil {
+		s.opt.onEvict(elem.Value.(*entry).key, reason)
+	}
+}
+
+func (s *shard) delete(elem *list.Element) {
+	e := s.lru.Remove(elem).(*entry)
+	delete(s.items, e.key)
+	s.bytes -= e.size
+}
+
+func (e *entry) sizeOf() int {
+	size := len(e.key) + len(e.data)
+	for field, data := range e.fields {
+		size += len(field) + len(data)
+	}
+	return size
+}
+
+func expireAt(now time.Time, ttl *time.Duration) time.Time {
+	if ttl == nil || *ttl <= 0 {
+		return time.Time{}
+	}
+	return now.Add(*ttl)
+}
+
+func copyBytes(data []byte) []byte {
+	if data == nil {
+		return nil
+	}
+	return append(make([]byte, 0, len(data)), data...)
+}
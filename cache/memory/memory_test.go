@@ -0,0 +1,139 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/erkesi/cacheaside/cache"
+)
+
+func TestCache(t *testing.T) {
+	now := time.Now()
+	var evicted []string
+	c := New(WithShards(1), WithMaxEntries(2), WithClock(func() time.Time {
+		return now
+	}), WithEvict(func(key string, reason EvictReason) {
+		evicted = append(evicted, key+":"+reason.String())
+	}))
+	ctx := context.Background()
+	ttl := time.Second
+	short := 100 * time.Millisecond
+	if err := c.MSet(ctx, &ttl, &cache.KV{Key: "k1", Data: []byte("v1")},
+		&cache.KV{Key: "k2", Data: []byte("v2"), TTL: &short}); err != nil {
+		t.Fatal(err)
+	}
+	res, _ := c.MGet(ctx, "k1", "k2", "k3")
+	if len(res) != 2 || string(res["k1"]) != "v1" || string(res["k2"]) != "v2" {
+		t.Fatalf("unexpected res %v", res)
+	}
+
+	now = now.Add(short)
+	res, _ = c.MGet(ctx, "k2")
+	if len(res) != 0 {
+		t.Fatalf("k2 should expire, res %v", res)
+	}
+
+	// k1 最近使用，写入 k4 时淘汰 k3
+	_ = c.MSet(ctx, nil, &cache.KV{Key: "k3", Data: []byte("v3")})
+	_, _ = c.MGet(ctx, "k1")
+	_ = c.MSet(ctx, nil, &cache.KV{Key: "k4", Data: []byte("v4")})
+	res, _ = c.MGet(ctx, "k1", "k3", "k4")
+	if len(res) != 2 || res["k3"] != nil {
+		t.Fatalf("unexpected res %v", res)
+	}
+	if fmt.Sprint(evicted) != "[k2:Expired k3:Capacity]" {
+		t.Fatalf("unexpected evicted %v", evicted)
+	}
+
+	_ = c.MDel(ctx, "k1")
+	if res, _ = c.MGet(ctx, "k1"); len(res) != 0 || c.Len() != 1 {
+		t.Fatalf("k1 should be deleted, res %v", res)
+	}
+}
+
+func TestCacheMaxBytes(t *testing.T) {
+	c := New(WithShards(1), WithMaxBytes(10))
+	ctx := context.Background()
+	_ = c.MSet(ctx, nil, &cache.KV{Key: "k1", Data: []byte("1234")})
+	_ = c.MSet(ctx, nil, &cache.KV{Key: "k2", Data: []byte("1234")})
+	res, _ := c.MGet(ctx, "k1", "k2")
+	if len(res) != 1 || res["k2"] == nil {
+		t.Fatalf("unexpected res %v", res)
+	}
+}
+
+func TestHCache(t *testing.T) {
+	now := time.Now()
+	c := New(WithClock(func() time.Time {
+		return now
+	}))
+	ctx := context.Background()
+	ttl := time.Second
+	if err := c.HMSet(ctx, "h", &ttl, &cache.KV{Key: "f1", Data: []byte("v1")},
+		&cache.KV{Key: "f2", Data: []byte("v2")}); err != nil {
+		t.Fatal(err)
+	}
+	// ttl 为空时保留原过期时间
+	_ = c.HMSet(ctx, "h", nil, &cache.KV{Key: "f3", Data: []byte("v3")})
+	res, _ := c.HMGet(ctx, "h", "f1", "f3", "f4")
+	if len(res) != 2 || string(res["f3"]) != "v3" {
+		t.Fatalf("unexpected res %v", res)
+	}
+	if res, _ = c.MGet(ctx, "h"); len(res) != 0 {
+		t.Fatalf("hash should not be read as string, res %v", res)
+	}
+
+	_ = c.HMDel(ctx, "h", "f1")
+	if res, _ = c.HMGet(ctx, "h", "f1", "f2"); len(res) != 1 || res["f2"] == nil {
+		t.Fatalf("unexpected res %v", res)
+	}
+
+	now = now.Add(ttl)
+	if res, _ = c.HMGet(ctx, "h", "f2", "f3"); len(res) != 0 {
+		t.Fatalf("hash should expire, res %v", res)
+	}
+
+	_ = c.HMSet(ctx, "h", nil, &cache.KV{Key: "f1", Data: []byte("v1")})
+	_ = c.HDel(ctx, "h")
+	if res, _ = c.HMGet(ctx, "h", "f1"); len(res) != 0 {
+		t.Fatalf("hash should be deleted, res %v", res)
+	}
+}
+
+func TestCacheConcurrent(t *testing.T) {
+	c := New(WithMaxEntries(64))
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				key := fmt.Sprintf("k%d", (i*1000+j)%128)
+				_ = c.MSet(ctx, nil, &cache.KV{Key: key, Data: []byte(key)})
+				res, _ := c.MGet(ctx, key)
+				if data, ok := res[key]; ok && string(data) != key {
+					t.Errorf("unexpected data %s", data)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	if c.Len() > 64+defaultShards {
+		t.Fatalf("too many entries %d", c.Len())
+	}
+}
+
+func TestCacheClear(t *testing.T) {
+	c := New()
+	ctx := context.Background()
+	_ = c.MSet(ctx, nil, &cache.KV{Key: "k1", Data: []byte("v1")})
+	_ = c.HMSet(ctx, "h", nil, &cache.KV{Key: "f1", Data: []byte("v1")})
+	c.Clear()
+	if c.Len() != 0 {
+		t.Fatalf("unexpected len %d", c.Len())
+	}
+}
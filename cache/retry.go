@@ -0,0 +1,225 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+type RetryOption struct {
+	attempts   int
+	backoff    time.Duration
+	maxBackoff time.Duration
+	jitter     float64
+	retryable  func(err error) bool
+}
+
+type RetryOptFn func(opt *RetryOption)
+
+// WithRetryAttempts 最大尝试次数（含首次），默认 3，小于 1 时按 1 处理
+func WithRetryAttempts(n int) RetryOptFn {
+	return func(opt *RetryOption) {
+		opt.attempts = n
+	}
+}
+
+// WithRetryBackoff 初始退避时间与最大退避时间，默认 10ms 与 1s，退避时间按指数增长
+func WithRetryBackoff(backoff, maxBackoff time.Duration) RetryOptFn {
+	return func(opt *RetryOption) {
+		opt.backoff = backoff
+		opt.maxBackoff = maxBackoff
+	}
+}
+
+// WithRetryJitter 退避时间在 ±fraction 比例内随机浮动，默认 0.2
+func WithRetryJitter(fraction float64) RetryOptFn {
+	return func(opt *RetryOption) {
+		opt.jitter = fraction
+	}
+}
+
+// WithRetryClassifier 判断错误是否可重试，默认除 ctx 结束与 ErrBreakerOpen 外均重试
+func WithRetryClassifier(retryable func(err error) bool) RetryOptFn {
+	return func(opt *RetryOption) {
+		opt.retryable = retryable
+	}
+}
+
+// Retryable 默认的可重试错误判断
+func Retryable(err error) bool {
+	return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) &&
+		!errors.Is(err, ErrBreakerOpen)
+}
+
+// Retrier 按指数退避重试，可在 Cacher 与 HCacher 间共享
+type Retrier struct {
+	opt *RetryOption
+}
+
+func NewRetrier(opts ...RetryOptFn) *Retrier {
+	opt := &RetryOption{
+		attempts:   3,
+		backoff:    10 * time.Millisecond,
+		maxBackoff: time.Second,
+		jitter:     0.2,
+		retryable:  Retryable,
+	}
+	for _, fn := range opts {
+		fn(opt)
+	}
+	if opt.attempts < 1 {
+		opt.attempts = 1
+	}
+	return &Retrier{opt: opt}
+}
+
+// Do 执行 fn，可重试的错误按指数退避重试，直至成功、达到次数上限或 ctx 结束；
+// 剩余时间不足以完成退避时不再重试
+func (r *Retrier) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	var err error
+	for i := 0; i < r.opt.attempts; i++ {
+		if i > 0 {
+			delay := r.delay(i)
+			if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+				return err
+			}
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return fmt.Errorf("%w, last error:%v", ctx.Err(), err)
+			}
+		}
+		if err = fn(ctx); err == nil || !r.opt.retryable(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// delay 第 i 次重试前的退避时间
+func (r *Retrier) delay(i int) time.Duration {
+	d := r.opt.backoff << (i - 1)
+	if d <= 0 || (r.opt.maxBackoff > 0 && d > r.opt.maxBackoff) {
+		d = r.opt.maxBackoff
+	}
+	if r.opt.jitter > 0 {
+		d = time.Duration(float64(d) * (1 + r.opt.jitter*(2*rand.Float64()-1)))
+	}
+	return d
+}
+
+// RetryCacher 失败时重试的 Cacher
+type RetryCacher struct {
+	cacher  Cacher
+	retrier *Retrier
+}
+
+func NewRetryCacher(cacher Cacher, retrier *Retrier) *RetryCacher {
+	return &RetryCacher{
+		cacher:  cacher,
+		retrier: retrier,
+	}
+}
+
+func (c *RetryCacher) MSet(ctx context.Context, ttl *time.Duration, kvs ...*KV) error {
+	return c.retrier.Do(ctx, func(ctx context.Context) error {
+		return c.cacher.MSet(ctx, ttl, kvs...)
+	})
+}
+
+func (c *RetryCacher) MGet(ctx context.Context, keys ...string) (map[string][]byte, error) {
+	var res map[string][]byte
+	err := c.retrier.Do(ctx, func(ctx context.Context) error {
+		var err error
+		res, err = c.cacher.MGet(ctx, keys...)
+		return err
+	})
+	return res, err
+}
+
+func (c *RetryCacher) MDel(ctx context.Context, keys ...string) error {
+	return c.retrier.Do(ctx, func(ctx context.Context) error {
+		return c.cacher.MDel(ctx, keys...)
+	})
+}
+
+// MGetTTL 被包装缓存实现 TTLCacher 时透传剩余过期时间，否则等同于 MGet
+func (c *RetryCacher) MGetTTL(ctx context.Context, keys ...string) (map[string][]byte, map[string]time.Duration, error) {
+	var res map[string][]byte
+	var ttls map[string]time.Duration
+	err := c.retrier.Do(ctx, func(ctx context.Context) error {
+		var err error
+		res, ttls, err = mGetTTL(ctx, c.cacher, keys...)
+		return err
+	})
+	return res, ttls, err
+}
+
+// Available 透传被包装缓存的可用性
+func (c *RetryCacher) Available() bool {
+	a, ok := c.cacher.(Availabler)
+	return !ok || a.Available()
+}
+
+// RetryHCacher 失败时重试的 HCacher
+type RetryHCacher struct {
+	hcacher HCacher
+	retrier *Retrier
+}
+
+func NewRetryHCacher(hcacher HCacher, retrier *Retrier) *RetryHCacher {
+	return &RetryHCacher{
+		hcacher: hcacher,
+		retrier: retrier,
+	}
+}
+
+func (c *RetryHCacher) HMSet(ctx context.Context, key string, ttl *time.Duration, kvs ...*KV) error {
+	return c.retrier.Do(ctx, func(ctx context.Context) error {
+		return c.hcacher.HMSet(ctx, key, ttl, kvs...)
+	})
+}
+
+func (c *RetryHCacher) HMGet(ctx context.Context, key string, fields ...string) (map[string][]byte, error) {
+	var res map[string][]byte
+	err := c.retrier.Do(ctx, func(ctx context.Context) error {
+		var err error
+		res, err = c.hcacher.HMGet(ctx, key, fields...)
+		return err
+	})
+	return res, err
+}
+
+func (c *RetryHCacher) HDel(ctx context.Context, key string) error {
+	return c.retrier.Do(ctx, func(ctx context.Context) error {
+		return c.hcacher.HDel(ctx, key)
+	})
+}
+
+func (c *RetryHCacher) HMDel(ctx context.Context, key string, fields ...string) error {
+	return c.retrier.Do(ctx, func(ctx context.Context) error {
+		return c.hcacher.HMDel(ctx, key, fields...)
+	})
+}
+
+// HMGetTTL 被包装缓存实现 HTTLCacher 时透传剩余过期时间，否则等同于 HMGet
+func (c *RetryHCacher) HMGetTTL(ctx context.Context, key string, fields ...string) (map[string][]byte, *time.Duration, error) {
+	var res map[string][]byte
+	var ttl *time.Duration
+	err := c.retrier.Do(ctx, func(ctx context.Context) error {
+		var err error
+		res, ttl, err = hmGetTTL(ctx, c.hcacher, key, fields...)
+		return err
+	})
+	return res, ttl, err
+}
+
+// Available 透传被包装缓存的可用性
+func (c *RetryHCacher) Available() bool {
+	a, ok := c.hcacher.(Availabler)
+	return !ok || a.Available()
+}
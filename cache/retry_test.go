@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+)
+
+func TestRetryCacher(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	transient := errors.New("connection reset")
+	permanent := errors.New("wrong type")
+	mcache := NewMockCacher(ctrl)
+	gomock.InOrder(
+		mcache.EXPECT().MSet(gomock.Any(), gomock.Any(), gomock.Any()).Return(transient).Times(2),
+		mcache.EXPECT().MSet(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil),
+		mcache.EXPECT().MDel(gomock.Any(), "k").Return(permanent),
+		mcache.EXPECT().MGet(gomock.Any(), "k").Return(nil, transient).Times(3),
+	)
+
+	c := NewRetryCacher(mcache, NewRetrier(WithRetryAttempts(3), WithRetryBackoff(time.Millisecond, 5*time.Millisecond),
+		WithRetryClassifier(func(err error) bool {
+			return Retryable(err) && !errors.Is(err, permanent)
+		})))
+	ctx := context.Background()
+	if err := c.MSet(ctx, nil, &KV{Key: "k", Data: []byte("v")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.MDel(ctx, "k"); !errors.Is(err, permanent) {
+		t.Fatalf("unexpected err %v", err)
+	}
+	if _, err := c.MGet(ctx, "k"); !errors.Is(err, transient) {
+		t.Fatalf("unexpected err %v", err)
+	}
+}
+
+func TestRetryDeadline(t *testing.T) {
+	var calls int
+	r := NewRetrier(WithRetryAttempts(5), WithRetryBackoff(time.Second, time.Second))
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := r.Do(ctx, func(ctx context.Context) error {
+		calls++
+		return errors.New("timeout")
+	})
+	if err == nil || calls != 1 || time.Since(start) > 50*time.Millisecond {
+		t.Fatalf("unexpected err %v, calls %d", err, calls)
+	}
+}
+
+func TestRetryAttemptsAtLeastOnce(t *testing.T) {
+	for _, n := range []int{0, -1} {
+		var calls int
+		err := NewRetrier(WithRetryAttempts(n)).Do(context.Background(), func(ctx context.Context) error {
+			calls++
+			return nil
+		})
+		if err != nil || calls != 1 {
+			t.Fatalf("attempts %d: unexpected err %v, calls %d", n, err, calls)
+		}
+	}
+}
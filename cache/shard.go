@@ -0,0 +1,316 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"hash/crc32"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const defaultShardReplicas = 160
+
+// ErrNoShardNode 分片缓存没有节点
+var ErrNoShardNode = errors.New("cache: no shard node")
+
+type ShardOption struct {
+	replicas int
+}
+
+type ShardOptFn func(opt *ShardOption)
+
+// WithShardReplicas 每个节点在哈希环上的虚拟节点数，默认 160，越大分布越均匀
+func WithShardReplicas(n int) ShardOptFn {
+	return func(opt *ShardOption) {
+		opt.replicas = n
+	}
+}
+
+// ring 一致性哈希环，增删节点时只有该节点负责的 key 重新映射
+type ring struct {
+	replicas int
+	hashes   []uint32
+	owners   map[uint32]string
+}
+
+func newRing(replicas int) *ring {
+	if replicas <= 0 {
+		replicas = defaultShardReplicas
+	}
+	return &ring{
+		replicas: replicas,
+		owners:   make(map[uint32]string),
+	}
+}
+
+func (r *ring) add(node string) {
+	for i := 0; i < r.replicas; i++ {
+		h := crc32.ChecksumIEEE([]byte(node + "#" + strconv.Itoa(i)))
+		if _, ok := r.owners[h]; ok {
+			continue
+		}
+		r.owners[h] = node
+		r.hashes = append(r.hashes, h)
+	}
+	sort.Slice(r.hashes, func(i, j int) bool {
+		return r.hashes[i] < r.hashes[j]
+	})
+}
+
+func (r *ring) remove(node string) {
+	hashes := r.hashes[:0]
+	for _, h := range r.hashes {
+		if r.owners[h] == node {
+			delete(r.owners, h)
+			continue
+		}
+		hashes = append(hashes, h)
+	}
+	r.hashes = hashes
+}
+
+// get key 所属的节点，环为空时返回空
+func (r *ring) get(key string) string {
+	if len(r.hashes) == 0 {
+		return ""
+	}
+	h := crc32.ChecksumIEEE([]byte(key))
+	i := sort.Search(len(r.hashes), func(i int) bool {
+		return r.hashes[i] >= h
+	})
+	if i == len(r.hashes) {
+		i = 0
+	}
+	return r.owners[r.hashes[i]]
+}
+
+// ShardCacher 按一致性哈希将 key 分布到多个 Cacher（如多个独立的 redis 实例），多个节点的操作并发执行
+type ShardCacher struct {
+	mu    sync.RWMutex
+	ring  *ring
+	nodes map[string]Cacher
+}
+
+// NewShardCacher nodes 为节点名到 Cacher 的映射，节点名决定其在哈希环上的位置
+func NewShardCacher(nodes map[string]Cacher, opts ...ShardOptFn) *ShardCacher {
+	opt := &ShardOption{replicas: defaultShardReplicas}
+	for _, fn := range opts {
+		fn(opt)
+	}
+	c := &ShardCacher{
+		ring:  newRing(opt.replicas),
+		nodes: make(map[string]Cacher, len(nodes)),
+	}
+	for name, node := range nodes {
+		c.AddNode(name, node)
+	}
+	return c
+}
+
+// AddNode 增加或替换节点
+func (c *ShardCacher) AddNode(name string, node Cacher) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.nodes[name]; !ok {
+		c.ring.add(name)
+	}
+	c.nodes[name] = node
+}
+
+// RemoveNode 删除节点，其负责的 key 重新映射到环上的下一个节点
+func (c *ShardCacher) RemoveNode(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.nodes[name]; ok {
+		c.ring.remove(name)
+		delete(c.nodes, name)
+	}
+}
+
+func (c *ShardCacher) MSet(ctx context.Context, ttl *time.Duration, kvs ...*KV) error {
+	keys := make([]string, 0, len(kvs))
+	key2KV := make(map[string]*KV, len(kvs))
+	for _, kv := range kvs {
+		keys = append(keys, kv.Key)
+		key2KV[kv.Key] = kv
+	}
+	return c.fanOut(keys, func(node Cacher, keys []string) error {
+		nodeKVs := make([]*KV, 0, len(keys))
+		for _, key := range keys {
+			nodeKVs = append(nodeKVs, key2KV[key])
+		}
+		return node.MSet(ctx, ttl, nodeKVs...)
+	})
+}
+
+func (c *ShardCacher) MGet(ctx context.Context, keys ...string) (map[string][]byte, error) {
+	var mu sync.Mutex
+	key2Data := make(map[string][]byte, len(keys))
+	err := c.fanOut(keys, func(node Cacher, keys []string) error {
+		res, err := node.MGet(ctx, keys...)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		for key, data := range res {
+			key2Data[key] = data
+		}
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return key2Data, nil
+}
+
+func (c *ShardCacher) MDel(ctx context.Context, keys ...string) error {
+	return c.fanOut(keys, func(node Cacher, keys []string) error {
+		return node.MDel(ctx, keys...)
+	})
+}
+
+// fanOut 按节点分组 keys 并发执行 fn，返回第一个错误
+func (c *ShardCacher) fanOut(keys []string, fn func(node Cacher, keys []string) error) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	c.mu.RLock()
+	if len(c.nodes) == 0 {
+		c.mu.RUnlock()
+		return ErrNoShardNode
+	}
+	groups := make(map[string][]string)
+	for _, key := range keys {
+		name := c.ring.get(key)
+		groups[name] = append(groups[name], key)
+	}
+	nodes := make(map[string]Cacher, len(groups))
+	for name := range groups {
+		nodes[name] = c.nodes[name]
+	}
+	c.mu.RUnlock()
+	return parallel(len(groups), func(do func(func() error)) {
+		for name, keys := range groups {
+			node, keys := nodes[name], keys
+			do(func() error {
+				return fn(node, keys)
+			})
+		}
+	})
+}
+
+// ShardHCacher 按 hash key 一致性哈希将 hash 分布到多个 HCacher，同一个 hash 的所有 field 在同一个节点
+type ShardHCacher struct {
+	mu    sync.RWMutex
+	ring  *ring
+	nodes map[string]HCacher
+}
+
+// NewShardHCacher nodes 为节点名到 HCacher 的映射，节点名决定其在哈希环上的位置
+func NewShardHCacher(nodes map[string]HCacher, opts ...ShardOptFn) *ShardHCacher {
+	opt := &ShardOption{replicas: defaultShardReplicas}
+	for _, fn := range opts {
+		fn(opt)
+	}
+	c := &ShardHCacher{
+		ring:  newRing(opt.replicas),
+		nodes: make(map[string]HCacher, len(nodes)),
+	}
+	for name, node := range nodes {
+		c.AddNode(name, node)
+	}
+	return c
+}
+
+// AddNode 增加或替换节点
+func (c *ShardHCacher) AddNode(name string, node HCacher) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.nodes[name]; !ok {
+		c.ring.add(name)
+	}
+	c.nodes[name] = node
+}
+
+// RemoveNode 删除节点，其负责的 hash 重新映射到环上的下一个节点
+func (c *ShardHCacher) RemoveNode(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.nodes[name]; ok {
+		c.ring.remove(name)
+		delete(c.nodes, name)
+	}
+}
+
+func (c *ShardHCacher) HMSet(ctx context.Context, key string, ttl *time.Duration, kvs ...*KV) error {
+	node, err := c.node(key)
+	if err != nil {
+		return err
+	}
+	return node.HMSet(ctx, key, ttl, kvs...)
+}
+
+func (c *ShardHCacher) HMGet(ctx context.Context, key string, fields ...string) (map[string][]byte, error) {
+	node, err := c.node(key)
+	if err != nil {
+		return nil, err
+	}
+	return node.HMGet(ctx, key, fields...)
+}
+
+func (c *ShardHCacher) HDel(ctx context.Context, key string) error {
+	node, err := c.node(key)
+	if err != nil {
+		return err
+	}
+	return node.HDel(ctx, key)
+}
+
+func (c *ShardHCacher) HMDel(ctx context.Context, key string, fields ...string) error {
+	node, err := c.node(key)
+	if err != nil {
+		return err
+	}
+	return node.HMDel(ctx, key, fields...)
+}
+
+func (c *ShardHCacher) node(key string) (HCacher, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if len(c.nodes) == 0 {
+		return nil, ErrNoShardNode
+	}
+	return c.nodes[c.ring.get(key)], nil
+}
+
+// parallel 并发执行 add 加入的任务，返回第一个错误
+func parallel(n int, add func(do func(func() error))) error {
+	if n == 1 {
+		var err error
+		add(func(fn func() error) {
+			err = fn()
+		})
+		return err
+	}
+	var (
+		wg   sync.WaitGroup
+		once sync.Once
+		err  error
+	)
+	add(func(fn func() error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if e := fn(); e != nil {
+				once.Do(func() {
+					err = e
+				})
+			}
+		}()
+	})
+	wg.Wait()
+	return err
+}
@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+)
+
+func TestRing(t *testing.T) {
+	r := newRing(defaultShardReplicas)
+	for i := 0; i < 4; i++ {
+		r.add(fmt.Sprintf("node%d", i))
+	}
+	owners := make(map[string]string, 10000)
+	counts := make(map[string]int)
+	for i := 0; i < 10000; i++ {
+		key := fmt.Sprintf("key%d", i)
+		owners[key] = r.get(key)
+		counts[owners[key]]++
+	}
+	for node, n := range counts {
+		if n < 1500 || n > 3500 {
+			t.Fatalf("unbalanced node %s %d", node, n)
+		}
+	}
+
+	// 增加节点只有部分 key 映射到新节点，其余 key 不变
+	r.add("node4")
+	for key, owner := range owners {
+		if node := r.get(key); node != owner && node != "node4" {
+			t.Fatalf("key %s moved from %s to %s", key, owner, node)
+		}
+	}
+	r.remove("node4")
+	for key, owner := range owners {
+		if node := r.get(key); node != owner {
+			t.Fatalf("key %s moved from %s to %s", key, owner, node)
+		}
+	}
+}
+
+func TestShardCacher(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	nodes := map[string]Cacher{}
+	mocks := map[string]*MockCacher{}
+	for _, name := range []string{"a", "b", "c"} {
+		m := NewMockCacher(ctrl)
+		nodes[name], mocks[name] = m, m
+	}
+	c := NewShardCacher(nodes)
+	keys := make([]string, 0, 30)
+	groups := make(map[string][]string)
+	for i := 0; i < 30; i++ {
+		key := fmt.Sprintf("key%d", i)
+		keys = append(keys, key)
+		groups[c.ring.get(key)] = append(groups[c.ring.get(key)], key)
+	}
+	for name, keys := range groups {
+		res := map[string][]byte{keys[0]: []byte(keys[0])}
+		args := make([]interface{}, 0, len(keys))
+		for _, key := range keys {
+			args = append(args, key)
+		}
+		mocks[name].EXPECT().MGet(gomock.Any(), args...).Return(res, nil)
+		mocks[name].EXPECT().MDel(gomock.Any(), args...).Return(nil)
+	}
+
+	ctx := context.Background()
+	res, err := c.MGet(ctx, keys...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != len(groups) {
+		t.Fatalf("unexpected res %v", res)
+	}
+	if err = c.MDel(ctx, keys...); err != nil {
+		t.Fatal(err)
+	}
+
+	for name := range nodes {
+		c.RemoveNode(name)
+	}
+	if _, err = c.MGet(ctx, "key"); err != ErrNoShardNode {
+		t.Fatalf("unexpected err %v", err)
+	}
+}
@@ -0,0 +1,191 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// DefaultL1TTL l1TTL <= 0 时 l1 的过期时间，避免 l1 中的数据不过期
+const DefaultL1TTL = time.Minute
+
+// TwoLevelCacher 两级缓存，先查询本地一级缓存（l1），未命中时查询远程二级缓存（l2）并回填 l1；
+// l1 查询或写入失败时按未命中处理，不影响 l2 的结果
+type TwoLevelCacher struct {
+	l1    Cacher
+	l2    Cacher
+	l1TTL time.Duration
+}
+
+// NewTwoLevelCacher l1TTL 为 l1 的过期时间，不超过 l2 中的剩余过期时间（l2 实现 TTLCacher 时），
+// l1TTL <= 0 时为 DefaultL1TTL
+func NewTwoLevelCacher(l1, l2 Cacher, l1TTL time.Duration) *TwoLevelCacher {
+	return &TwoLevelCacher{
+		l1:    l1,
+		l2:    l2,
+		l1TTL: l1TTLOrDefault(l1TTL),
+	}
+}
+
+// MSet 先写入 l2，成功后写入 l1
+func (c *TwoLevelCacher) MSet(ctx context.Context, ttl *time.Duration, kvs ...*KV) error {
+	if err := c.l2.MSet(ctx, ttl, kvs...); err != nil {
+		return err
+	}
+	l1KVs := make([]*KV, 0, len(kvs))
+	for _, kv := range kvs {
+		kvTTL := ttl
+		if kv.TTL != nil {
+			kvTTL = kv.TTL
+		}
+		l1KV := *kv
+		l1KV.TTL = capTTL(c.l1TTL, kvTTL)
+		l1KVs = append(l1KVs, &l1KV)
+	}
+	_ = c.l1.MSet(ctx, nil, l1KVs...)
+	return nil
+}
+
+func (c *TwoLevelCacher) MGet(ctx context.Context, keys ...string) (map[string][]byte, error) {
+	key2Data, err := c.l1.MGet(ctx, keys...)
+	if err != nil || key2Data == nil {
+		key2Data = make(map[string][]byte, len(keys))
+	}
+	missKeys := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if _, ok := key2Data[key]; !ok {
+			missKeys = append(missKeys, key)
+		}
+	}
+	if len(missKeys) == 0 {
+		return key2Data, nil
+	}
+	l2Data, ttls, err := mGetTTL(ctx, c.l2, missKeys...)
+	if err != nil {
+		return nil, err
+	}
+	kvs := make([]*KV, 0, len(l2Data))
+	for key, data := range l2Data {
+		key2Data[key] = data
+		var remain *time.Duration
+		if ttl, ok := ttls[key]; ok {
+			if ttl <= 0 {
+				continue
+			}
+			remain = &ttl
+		}
+		kvs = append(kvs, &KV{Key: key, Data: data, TTL: capTTL(c.l1TTL, remain)})
+	}
+	if len(kvs) > 0 {
+		_ = c.l1.MSet(ctx, nil, kvs...)
+	}
+	return key2Data, nil
+}
+
+// MDel 删除 l2 与 l1，l1 在 l2 之后删除，避免并发查询以 l2 中的旧值回填 l1
+func (c *TwoLevelCacher) MDel(ctx context.Context, keys ...string) error {
+	err := c.l2.MDel(ctx, keys...)
+	if l1Err := c.l1.MDel(ctx, keys...); err == nil {
+		err = l1Err
+	}
+	return err
+}
+
+// Available 透传 l2 的可用性
+func (c *TwoLevelCacher) Available() bool {
+	a, ok := c.l2.(Availabler)
+	return !ok || a.Available()
+}
+
+// TwoLevelHCacher 两级 hash 缓存，同 TwoLevelCacher
+type TwoLevelHCacher struct {
+	l1    HCacher
+	l2    HCacher
+	l1TTL time.Duration
+}
+
+// NewTwoLevelHCacher l1TTL 为 l1 的过期时间，不超过 l2 中的剩余过期时间（l2 实现 HTTLCacher 时），
+// l1TTL <= 0 时为 DefaultL1TTL
+func NewTwoLevelHCacher(l1, l2 HCacher, l1TTL time.Duration) *TwoLevelHCacher {
+	return &TwoLevelHCacher{
+		l1:    l1,
+		l2:    l2,
+		l1TTL: l1TTLOrDefault(l1TTL),
+	}
+}
+
+// HMSet 先写入 l2，成功后写入 l1
+func (c *TwoLevelHCacher) HMSet(ctx context.Context, key string, ttl *time.Duration, kvs ...*KV) error {
+	if err := c.l2.HMSet(ctx, key, ttl, kvs...); err != nil {
+		return err
+	}
+	_ = c.l1.HMSet(ctx, key, capTTL(c.l1TTL, ttl), kvs...)
+	return nil
+}
+
+func (c *TwoLevelHCacher) HMGet(ctx context.Context, key string, fields ...string) (map[string][]byte, error) {
+	field2Data, err := c.l1.HMGet(ctx, key, fields...)
+	if err != nil || field2Data == nil {
+		field2Data = make(map[string][]byte, len(fields))
+	}
+	missFields := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if _, ok := field2Data[field]; !ok {
+			missFields = append(missFields, field)
+		}
+	}
+	if len(missFields) == 0 {
+		return field2Data, nil
+	}
+	l2Data, remain, err := hmGetTTL(ctx, c.l2, key, missFields...)
+	if err != nil {
+		return nil, err
+	}
+	kvs := make([]*KV, 0, len(l2Data))
+	for field, data := range l2Data {
+		field2Data[field] = data
+		kvs = append(kvs, &KV{Key: field, Data: data})
+	}
+	if len(kvs) > 0 && (remain == nil || *remain > 0) {
+		_ = c.l1.HMSet(ctx, key, capTTL(c.l1TTL, remain), kvs...)
+	}
+	return field2Data, nil
+}
+
+// HDel 删除 l2 与 l1
+func (c *TwoLevelHCacher) HDel(ctx context.Context, key string) error {
+	err := c.l2.HDel(ctx, key)
+	if l1Err := c.l1.HDel(ctx, key); err == nil {
+		err = l1Err
+	}
+	return err
+}
+
+// HMDel 删除 l2 与 l1
+func (c *TwoLevelHCacher) HMDel(ctx context.Context, key string, fields ...string) error {
+	err := c.l2.HMDel(ctx, key, fields...)
+	if l1Err := c.l1.HMDel(ctx, key, fields...); err == nil {
+		err = l1Err
+	}
+	return err
+}
+
+// Available 透传 l2 的可用性
+func (c *TwoLevelHCacher) Available() bool {
+	a, ok := c.l2.(Availabler)
+	return !ok || a.Available()
+}
+
+func l1TTLOrDefault(l1TTL time.Duration) time.Duration {
+	if l1TTL <= 0 {
+		return DefaultL1TTL
+	}
+	return l1TTL
+}
+
+// capTTL l1 的过期时间，不超过 l2 的过期时间 ttl（为空或不大于 0 时不过期），l1 始终会过期
+func capTTL(l1TTL time.Duration, ttl *time.Duration) *time.Duration {
+	if ttl != nil && *ttl > 0 && *ttl < l1TTL {
+		return ttl
+	}
+	return &l1TTL
+}
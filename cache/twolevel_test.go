@@ -0,0 +1,160 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+)
+
+type ttlCacher struct {
+	*MockCacher
+	*MockTTLCacher
+}
+
+func TestTwoLevelCacher(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	l1 := NewMockCacher(ctrl)
+	l2 := ttlCacher{MockCacher: NewMockCacher(ctrl), MockTTLCacher: NewMockTTLCacher(ctrl)}
+	l1TTL := time.Minute
+	remain := 10 * time.Second
+	gomock.InOrder(
+		l1.EXPECT().MGet(gomock.Any(), "k1", "k2", "k3").Return(map[string][]byte{"k1": []byte("v1")}, nil),
+		l2.MockTTLCacher.EXPECT().MGetTTL(gomock.Any(), "k2", "k3").
+			Return(map[string][]byte{"k2": []byte("v2"), "k3": []byte("v3")}, map[string]time.Duration{"k2": remain}, nil),
+		l1.EXPECT().MSet(gomock.Any(), nil, gomock.Any()).DoAndReturn(
+			func(ctx context.Context, ttl *time.Duration, kvs ...*KV) error {
+				if len(kvs) != 2 {
+					t.Fatalf("unexpected kvs %v", kvs)
+				}
+				for _, kv := range kvs {
+					if (kv.Key == "k2" && *kv.TTL != remain) || (kv.Key == "k3" && *kv.TTL != l1TTL) {
+						t.Fatalf("unexpected ttl %s %v", kv.Key, *kv.TTL)
+					}
+				}
+				return nil
+			}),
+		l2.MockCacher.EXPECT().MDel(gomock.Any(), "k1").Return(nil),
+		l1.EXPECT().MDel(gomock.Any(), "k1").Return(nil),
+		l2.MockCacher.EXPECT().MSet(gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.New("down")),
+	)
+
+	c := NewTwoLevelCacher(l1, l2, l1TTL)
+	ctx := context.Background()
+	res, err := c.MGet(ctx, "k1", "k2", "k3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 3 || string(res["k2"]) != "v2" {
+		t.Fatalf("unexpected res %v", res)
+	}
+	if err = c.MDel(ctx, "k1"); err != nil {
+		t.Fatal(err)
+	}
+	// l2 写入失败时不写入 l1
+	if err = c.MSet(ctx, &l1TTL, &KV{Key: "k1", Data: []byte("v1")}); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestTwoLevelCacherDecoratedL2(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	l1 := NewMockCacher(ctrl)
+	l2 := ttlCacher{MockCacher: NewMockCacher(ctrl), MockTTLCacher: NewMockTTLCacher(ctrl)}
+	remain := 10 * time.Second
+	l1.EXPECT().MGet(gomock.Any(), "k").Return(nil, nil)
+	l2.MockTTLCacher.EXPECT().MGetTTL(gomock.Any(), "k").
+		Return(map[string][]byte{"k": []byte("v")}, map[string]time.Duration{"k": remain}, nil)
+	l1.EXPECT().MSet(gomock.Any(), nil, gomock.Any()).DoAndReturn(
+		func(ctx context.Context, ttl *time.Duration, kvs ...*KV) error {
+			if len(kvs) != 1 || *kvs[0].TTL != remain {
+				t.Fatalf("unexpected kvs %v", kvs)
+			}
+			return nil
+		})
+
+	var gets int
+	decorated := NewBreakerCacher(NewRetryCacher(Chain(l2, Middleware{
+		MGet: func(next MGetHandler) MGetHandler {
+			return func(ctx context.Context, keys ...string) (map[string][]byte, error) {
+				gets++
+				return next(ctx, keys...)
+			}
+		},
+	}), NewRetrier()), NewBreaker())
+	c := NewTwoLevelCacher(l1, decorated, time.Minute)
+	if _, err := c.MGet(context.Background(), "k"); err != nil {
+		t.Fatal(err)
+	}
+	if gets != 1 {
+		t.Fatalf("unexpected gets %d", gets)
+	}
+}
+
+func TestTwoLevelHCacher(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	l1 := NewMockHCacher(ctrl)
+	l2 := NewMockHCacher(ctrl)
+	l1TTL := time.Minute
+	ttl := 10 * time.Second
+	gomock.InOrder(
+		l2.EXPECT().HMSet(gomock.Any(), "h", &ttl, gomock.Any()).Return(nil),
+		l1.EXPECT().HMSet(gomock.Any(), "h", &ttl, gomock.Any()).Return(nil),
+		l1.EXPECT().HMGet(gomock.Any(), "h", "f1", "f2").Return(nil, errors.New("l1 error")),
+		l2.EXPECT().HMGet(gomock.Any(), "h", "f1", "f2").Return(map[string][]byte{"f1": []byte("v1")}, nil),
+		l1.EXPECT().HMSet(gomock.Any(), "h", &l1TTL, gomock.Any()).Return(nil),
+		l2.EXPECT().HMDel(gomock.Any(), "h", "f1").Return(nil),
+		l1.EXPECT().HMDel(gomock.Any(), "h", "f1").Return(nil),
+		l2.EXPECT().HDel(gomock.Any(), "h").Return(nil),
+		l1.EXPECT().HDel(gomock.Any(), "h").Return(nil),
+	)
+
+	c := NewTwoLevelHCacher(l1, l2, l1TTL)
+	ctx := context.Background()
+	if err := c.HMSet(ctx, "h", &ttl, &KV{Key: "f1", Data: []byte("v1")}); err != nil {
+		t.Fatal(err)
+	}
+	res, err := c.HMGet(ctx, "h", "f1", "f2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || string(res["f1"]) != "v1" {
+		t.Fatalf("unexpected res %v", res)
+	}
+	if err = c.HMDel(ctx, "h", "f1"); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.HDel(ctx, "h"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCapTTL(t *testing.T) {
+	second, minute, zero, defaultTTL := time.Second, time.Minute, time.Duration(0), DefaultL1TTL
+	for _, c := range []struct {
+		l1TTL time.Duration
+		ttl   *time.Duration
+		want  *time.Duration
+	}{
+		{l1TTL: minute, ttl: &second, want: &second},
+		{l1TTL: second, ttl: &minute, want: &second},
+		{l1TTL: second, ttl: nil, want: &second},
+		{l1TTL: second, ttl: &zero, want: &second},
+		{l1TTL: 0, ttl: &second, want: &second},
+		{l1TTL: 0, ttl: nil, want: &defaultTTL},
+		{l1TTL: -1, ttl: &zero, want: &defaultTTL},
+	} {
+		got := capTTL(l1TTLOrDefault(c.l1TTL), c.ttl)
+		if (got == nil) != (c.want == nil) || (got != nil && *got != *c.want) {
+			t.Fatalf("unexpected ttl %v for l1TTL %v", got, c.l1TTL)
+		}
+	}
+}
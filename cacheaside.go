@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
+	"math/rand"
 	"reflect"
 	"sort"
 	"strings"
@@ -11,16 +13,21 @@ import (
 
 	"github.com/erkesi/cacheaside/cache"
 	"github.com/erkesi/cacheaside/code"
-	"golang.org/x/sync/singleflight"
 )
 
 const (
 	keyFormat = "%s$%s"
+	// hashTagKeyFormat 带 redis cluster hash tag 的缓存 key，tag 相同的 key 在同一个 slot
+	hashTagKeyFormat = "%s${%s}%s"
 )
 
 type FetchSource func(ctx context.Context, keys []string,
 	extra ...interface{}) ([]interface{}, error)
 
+// FetchSourcePartial 回源查询，可单独返回部分 key 的错误，失败的 key 不回写缓存
+type FetchSourcePartial func(ctx context.Context, keys []string,
+	extra ...interface{}) ([]interface{}, map[string]error, error)
+
 type GenCacheKey func(ctx context.Context, v interface{},
 	extra ...interface{}) (string, error)
 
@@ -35,6 +42,8 @@ type CacheAside struct {
 	cache      cache.Cacher
 	hcache     cache.HCacher
 	namespance string
+	gen        *generation
+	refresh    *refreshPool
 	opts       []OptFn
 }
 
@@ -43,6 +52,8 @@ func NewCacheAside(code code.Coder, cache cache.Cacher, namespance string, opts
 		code:       code,
 		cache:      cache,
 		namespance: namespance,
+		gen:        newGenerationByOpts(namespance, opts),
+		refresh:    newRefreshPoolByOpts(opts),
 		opts:       opts,
 	}
 }
@@ -52,12 +63,57 @@ func NewHCacheAside(code code.Coder, hcache cache.HCacher, namespance string, op
 		code:       code,
 		hcache:     hcache,
 		namespance: namespance,
+		gen:        newGenerationByOpts(namespance, opts),
+		refresh:    newRefreshPoolByOpts(opts),
 		opts:       opts,
 	}
 }
 
+func newRefreshPoolByOpts(opts []OptFn) *refreshPool {
+	opt := newOption()
+	for _, fn := range opts {
+		fn(opt)
+	}
+	return &refreshPool{workers: opt.refreshWorkers, queueSize: opt.refreshQueueSize}
+}
+
+// Close 停止后台刷新，并等待进行中的刷新完成；刷新协程池由该 CacheAside 创建的所有 Fetcher 共享
+func (ca *CacheAside) Close(ctx context.Context) error {
+	return ca.refresh.close(ctx)
+}
+
+func newGenerationByOpts(namespance string, opts []OptFn) *generation {
+	opt := newOption()
+	for _, fn := range opts {
+		fn(opt)
+	}
+	if opt.genCounter == nil {
+		return nil
+	}
+	return newGeneration(opt.genCounter, namespance, opt.genTTL)
+}
+
+// InvalidateNamespace 递增 namespace 版本号，使该 namespace 下的所有缓存失效，需开启 WithNamespaceGeneration；
+// 其他实例在本地缓存的版本号过期后生效
+func (ca *CacheAside) InvalidateNamespace(ctx context.Context) error {
+	if ca.gen == nil {
+		return errors.New("cacheaside: namespace generation is not enabled")
+	}
+	if _, err := ca.gen.incr(ctx); err != nil {
+		return fmt.Errorf("cacheaside: namespace generation incr error:%w", err)
+	}
+	return nil
+}
+
+// FetchPartial 同 Fetch，fetchSource 可单独返回部分 key 的错误，通过 Fetcher.MGetResults 获取每个 key 的查询结果
+func (ca *CacheAside) FetchPartial(fetchSource FetchSourcePartial, genCacheKey GenCacheKey, opts ...OptFn) *Fetcher {
+	f := ca.Fetch(nil, genCacheKey, opts...)
+	f.fetchSourcePartial = fetchSource
+	return f
+}
+
 func (ca *CacheAside) Fetch(fetchSource FetchSource, genCacheKey GenCacheKey, opts ...OptFn) *Fetcher {
-	opt := &Option{}
+	opt := newOption()
 	var allOpts []OptFn
 	allOpts = append(allOpts, ca.opts...)
 	allOpts = append(allOpts, opts...)
@@ -67,11 +123,15 @@ func (ca *CacheAside) Fetch(fetchSource FetchSource, genCacheKey GenCacheKey, op
 	return &Fetcher{
 		_Fetcher: &_Fetcher{
 			ca: &CacheAside{
-				code:  ca.code,
-				cache: ca.cache,
-                namespance: ca.namespance,
+				code:       ca.code,
+				cache:      ca.cache,
+				namespance: ca.namespance,
+				gen:        ca.gen,
 			},
-			opt: opt,
+			opt:       opt,
+			refresher: ca.refresher(opt),
+			limiter:   opt.newLimiter(),
+			delayer:   newDelayer(),
 		},
 		fetchSource: fetchSource,
 		genCacheKey: genCacheKey,
@@ -79,7 +139,7 @@ func (ca *CacheAside) Fetch(fetchSource FetchSource, genCacheKey GenCacheKey, op
 }
 
 func (ca *CacheAside) HFetch(fetchSource FetchSourceHash, genCacheHashField GenCacheHashField, opts ...OptFn) *HFetcher {
-	opt := &Option{}
+	opt := newOption()
 	var allOpts []OptFn
 	allOpts = append(allOpts, ca.opts...)
 	allOpts = append(allOpts, opts...)
@@ -89,11 +149,15 @@ func (ca *CacheAside) HFetch(fetchSource FetchSourceHash, genCacheHashField GenC
 	return &HFetcher{
 		_Fetcher: &_Fetcher{
 			ca: &CacheAside{
-				code:   ca.code,
-				hcache: ca.hcache,
-                namespance: ca.namespance,
+				code:       ca.code,
+				hcache:     ca.hcache,
+				namespance: ca.namespance,
+				gen:        ca.gen,
 			},
-			opt: opt,
+			opt:       opt,
+			refresher: ca.refresher(opt),
+			limiter:   opt.newLimiter(),
+			delayer:   newDelayer(),
 		},
 		fetchSource:       fetchSource,
 		genCacheHashField: genCacheHashField,
@@ -112,11 +176,52 @@ const (
 )
 
 type Option struct {
-	ttl                 *time.Duration
-	log                 Logger
-	_strategy           *Strategy
-	_cacheGetErrHandler func(ctx context.Context, err error, keys, fields []string, extra ...interface{})
-	_cacheSetErrHandler func(ctx context.Context, err error, keys, fields []string, extra ...interface{}) error
+	ttl                   *time.Duration
+	negativeTTL           *time.Duration
+	ttlJitter             float64
+	ttlFunc               func(ctx context.Context, key string, v interface{}) time.Duration
+	softTTL               *time.Duration
+	refreshWorkers        int
+	refreshQueueSize      int
+	earlyBeta             float64
+	delayedDelAttempts    int
+	delayedDelBackoff     time.Duration
+	_delayedDelErrHandler func(ctx context.Context, err error, keys, fields []string)
+	clock                 func() time.Time
+	genCounter            cache.Counter
+	genTTL                time.Duration
+	locker                cache.Locker
+	lockTTL               time.Duration
+	lockWait              time.Duration
+	lockFallback          bool
+	maxCacheBatch         int
+	maxSourceBatch        int
+	concurrency           int
+	sourceTimeout         time.Duration
+	cacheReadTimeout      time.Duration
+	cacheWriteTimeout     time.Duration
+	sourceRate            float64
+	sourceBurst           int
+	sourceConcurrency     int
+	limitPolicy           LimitPolicy
+	staleWindow           time.Duration
+	metrics               Metrics
+	tracer                Tracer
+	hashTag               func(key string) string
+	log                   Logger
+	_strategy             *Strategy
+	_cacheGetErrHandler   func(ctx context.Context, err error, keys, fields []string, extra ...interface{})
+	_cacheSetErrHandler   func(ctx context.Context, err error, keys, fields []string, extra ...interface{}) error
+}
+
+func newOption() *Option {
+	return &Option{
+		delayedDelAttempts: defaultDelayedDelAttempts,
+		delayedDelBackoff:  defaultDelayedDelBackoff,
+		lockFallback:       true,
+		concurrency:        defaultConcurrency,
+		staleWindow:        defaultStaleWindow,
+	}
 }
 
 func (o *Option) cacheGetErrHandler() func(ctx context.Context, err error, keys, fields []string, extra ...interface{}) {
@@ -145,6 +250,80 @@ func (o *Option) cacheSetErrHandler() func(ctx context.Context, err error, keys,
 	return nil
 }
 
+func (o *Option) newLimiter() *limiter {
+	return newLimiter(o.sourceRate, o.sourceBurst, o.sourceConcurrency, o.limitPolicy)
+}
+
+// refresher 开启软过期时返回共享的刷新协程池
+func (ca *CacheAside) refresher(opt *Option) *refresher {
+	if opt.softTTL == nil {
+		return nil
+	}
+	return ca.refresh.get()
+}
+
+// kvTTL 计算单个值的过期时间，val 为 nil 表示回源不存在
+func (o *Option) kvTTL(ctx context.Context, key string, val interface{}) *time.Duration {
+	ttl := o.ttl
+	if val == nil {
+		if o.negativeTTL != nil {
+			ttl = o.negativeTTL
+		}
+	} else if o.ttlFunc != nil {
+		if d := o.ttlFunc(ctx, key, val); d > 0 {
+			ttl = &d
+		}
+	}
+	return o.jitter(ttl)
+}
+
+// cacheTTL 写入缓存的过期时间，LimitPolicyServeStale 时在逻辑过期时间 ttl 上延长 staleWindow，
+// 以便限流时返回已过期的旧数据
+func (o *Option) cacheTTL(ttl *time.Duration) *time.Duration {
+	if ttl == nil || o.limitPolicy != LimitPolicyServeStale || o.staleWindow <= 0 {
+		return ttl
+	}
+	d := *ttl + o.staleWindow
+	return &d
+}
+
+// jitter 在 ttl 上下浮动 ttlJitter 比例，避免同一批写入的数据同时过期
+func (o *Option) jitter(ttl *time.Duration) *time.Duration {
+	if ttl == nil || o.ttlJitter <= 0 {
+		return ttl
+	}
+	d := time.Duration(float64(*ttl) * (1 + o.ttlJitter*(2*rand.Float64()-1)))
+	return &d
+}
+
+func (o *Option) now() time.Time {
+	if o.clock != nil {
+		return o.clock()
+	}
+	return time.Now()
+}
+
+// expireEarly XFetch 概率提前过期：now - delta * beta * ln(rand()) >= expire
+func (o *Option) expireEarly(now time.Time, env *envelope) bool {
+	if o.earlyBeta <= 0 || env.flags&flagExpire == 0 {
+		return false
+	}
+	gap := float64(env.delta) * o.earlyBeta * -math.Log(1-rand.Float64())
+	return float64(now.UnixNano())+gap >= float64(env.expire)
+}
+
+func (o *Option) delayedDelErrHandler() func(ctx context.Context, err error, keys, fields []string) {
+	if o._delayedDelErrHandler != nil {
+		return o._delayedDelErrHandler
+	}
+	if o.log != nil {
+		return func(ctx context.Context, err error, keys, fields []string) {
+			o.log.Wranf(ctx, "%v", err)
+		}
+	}
+	return nil
+}
+
 func (o *Option) strategy() Strategy {
 	if o._strategy == nil {
 		return StrategyFirstUseCache
@@ -186,16 +365,256 @@ func WithTTL(ttl time.Duration) OptFn {
 	}
 }
 
+// WithNegativeTTL 回源不存在的 key（field）以空值标记缓存的过期时间；不设置时空值以空数据按 ttl 缓存
+func WithNegativeTTL(negativeTTL time.Duration) OptFn {
+	return func(opt *Option) {
+		opt.negativeTTL = &negativeTTL
+	}
+}
+
+// WithTTLJitter 过期时间随机浮动比例（0~1），如 0.1 表示在 ttl 的 ±10% 内浮动
+func WithTTLJitter(fraction float64) OptFn {
+	return func(opt *Option) {
+		if fraction > 1 {
+			fraction = 1
+		}
+		opt.ttlJitter = fraction
+	}
+}
+
+// WithTTLFunc 按回源结果计算过期时间，key 为原始 key（不含 namespace，hash 为 field），返回值 <= 0 时使用 ttl
+func WithTTLFunc(ttlFunc func(ctx context.Context, key string, v interface{}) time.Duration) OptFn {
+	return func(opt *Option) {
+		opt.ttlFunc = ttlFunc
+	}
+}
+
+// WithSoftTTL 软过期时间（应小于 ttl），超过软过期时间的数据仍会返回，同时在后台回源刷新
+func WithSoftTTL(softTTL time.Duration) OptFn {
+	return func(opt *Option) {
+		opt.softTTL = &softTTL
+	}
+}
+
+// WithEarlyExpiration 概率提前过期（XFetch），越接近过期时间、上次回源耗时越长，提前回源的概率越大；
+// beta 通常取 1，大于 1 更倾向于提前回源，需同时设置 WithTTL
+func WithEarlyExpiration(beta float64) OptFn {
+	return func(opt *Option) {
+		opt.earlyBeta = beta
+	}
+}
+
+// WithClock 设置时钟，用于测试
+func WithClock(clock func() time.Time) OptFn {
+	return func(opt *Option) {
+		opt.clock = clock
+	}
+}
+
+// WithDelayedDelRetry 延迟删除失败时的重试次数（含首次，小于 1 时按 1 处理）与初始退避时间，默认 3 次、100ms，退避时间按指数增长
+func WithDelayedDelRetry(attempts int, backoff time.Duration) OptFn {
+	return func(opt *Option) {
+		opt.delayedDelAttempts = attempts
+		opt.delayedDelBackoff = backoff
+	}
+}
+
+// WithDelayedDelErrHandler 延迟删除最终失败时的处理，默认记录日志
+func WithDelayedDelErrHandler(delayedDelErrHandler func(ctx context.Context, err error, keys, fields []string)) OptFn {
+	return func(opt *Option) {
+		opt._delayedDelErrHandler = delayedDelErrHandler
+	}
+}
+
+// WithNamespaceGeneration 开启 namespace 版本号，key 前缀为 namespace:gen$，版本号存储于 counter 并在本地缓存 localTTL 时间；
+// 仅在 NewCacheAside/NewHCacheAside 中生效
+func WithNamespaceGeneration(counter cache.Counter, localTTL time.Duration) OptFn {
+	return func(opt *Option) {
+		opt.genCounter = counter
+		opt.genTTL = localTTL
+	}
+}
+
+// WithRefreshPool 后台刷新协程池的协程数与队列长度，默认 4 与 1024，队列满时丢弃刷新任务；
+// 仅在 NewCacheAside/NewHCacheAside 中生效，协程池由 CacheAside.Close 关闭
+func WithRefreshPool(workers, queueSize int) OptFn {
+	return func(opt *Option) {
+		opt.refreshWorkers = workers
+		opt.refreshQueueSize = queueSize
+	}
+}
+
+// WithFillLock 开启回源分布式锁，同一 key 仅由加锁成功的进程回源，其他进程在 wait 时间内轮询缓存等待填充；
+// lockTTL 应大于回源与回写缓存的耗时
+func WithFillLock(locker cache.Locker, lockTTL, wait time.Duration) OptFn {
+	return func(opt *Option) {
+		opt.locker = locker
+		opt.lockTTL = lockTTL
+		opt.lockWait = wait
+	}
+}
+
+// WithFillLockFallback 加锁失败或等待超时时是否直接回源，默认 true；为 false 时加锁失败返回错误，等待超时返回 ErrFillLockTimeout
+func WithFillLockFallback(fallback bool) OptFn {
+	return func(opt *Option) {
+		opt.lockFallback = fallback
+	}
+}
+
+// WithMaxCacheBatch 单次查询与写入缓存的最大 key（field）数，超过时分批并发执行，默认不限制
+func WithMaxCacheBatch(n int) OptFn {
+	return func(opt *Option) {
+		opt.maxCacheBatch = n
+	}
+}
+
+// WithMaxSourceBatch 单次回源的最大 key（field）数，超过时分批并发回源，默认不限制
+func WithMaxSourceBatch(n int) OptFn {
+	return func(opt *Option) {
+		opt.maxSourceBatch = n
+	}
+}
+
+// WithConcurrency 分批执行时的最大并发数，默认 4，n <= 0 时不限制
+func WithConcurrency(n int) OptFn {
+	return func(opt *Option) {
+		opt.concurrency = n
+	}
+}
+
+// WithSourceTimeout 单次回源的超时时间，默认不限制；回源不受调用方 ctx 取消的影响，调用方 ctx 结束时仅停止等待
+func WithSourceTimeout(timeout time.Duration) OptFn {
+	return func(opt *Option) {
+		opt.sourceTimeout = timeout
+	}
+}
+
+// WithCacheReadTimeout 单次查询缓存（MGet/HMGet）的超时时间，默认不限制；
+// 超时按查询缓存失败处理，StrategyCacheFailBackToSource 下回源查询
+func WithCacheReadTimeout(timeout time.Duration) OptFn {
+	return func(opt *Option) {
+		opt.cacheReadTimeout = timeout
+	}
+}
+
+// WithCacheWriteTimeout 单次写入缓存（MSet/HMSet）的超时时间，默认不限制
+func WithCacheWriteTimeout(timeout time.Duration) OptFn {
+	return func(opt *Option) {
+		opt.cacheWriteTimeout = timeout
+	}
+}
+
+// WithSourceRateLimit 回源限流（令牌桶），每秒最多 rate 次回源，允许突发 burst 次，默认不限制；
+// 分批回源时每批计一次，达到上限时的处理由 WithSourceLimitPolicy 设置
+func WithSourceRateLimit(rate float64, burst int) OptFn {
+	return func(opt *Option) {
+		opt.sourceRate = rate
+		opt.sourceBurst = burst
+	}
+}
+
+// WithSourceConcurrency 同时进行的最大回源数，默认不限制，达到上限时的处理由 WithSourceLimitPolicy 设置
+func WithSourceConcurrency(n int) OptFn {
+	return func(opt *Option) {
+		opt.sourceConcurrency = n
+	}
+}
+
+// WithSourceLimitPolicy 回源达到限流或并发上限时的处理策略，默认 LimitPolicyWait
+func WithSourceLimitPolicy(policy LimitPolicy) OptFn {
+	return func(opt *Option) {
+		opt.limitPolicy = policy
+	}
+}
+
+// WithStaleWindow LimitPolicyServeStale 时数据逻辑过期后在缓存中继续保留的时间，默认 5 分钟
+func WithStaleWindow(window time.Duration) OptFn {
+	return func(opt *Option) {
+		opt.staleWindow = window
+	}
+}
+
+// WithMetrics 设置监控指标，可使用 NewExpvarMetrics
+func WithMetrics(metrics Metrics) OptFn {
+	return func(opt *Option) {
+		opt.metrics = metrics
+	}
+}
+
+// WithTracer 设置链路追踪，每次读取生成一个 span，并为查询缓存、等待回源、回源、编解码、写入缓存生成子 span
+func WithTracer(tracer Tracer) OptFn {
+	return func(opt *Option) {
+		opt.tracer = tracer
+	}
+}
+
+// WithHashTag 缓存 key 加入 redis cluster hash tag（namespace${tag}key），tag 相同的 key 在同一个 slot，
+// 可用单条命令读写；tag 为空时不加入，hash 以 key 计算 tag
+func WithHashTag(tag func(key string) string) OptFn {
+	return func(opt *Option) {
+		opt.hashTag = tag
+	}
+}
+
+// timeoutContext timeout > 0 时为 ctx 设置超时时间
+func timeoutContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return ctx, func() {}
+}
+
+// sourceContext 回源使用的 ctx，保留 ctx 中的值，不随 ctx 取消，超时时间由 WithSourceTimeout 设置
+func (o *Option) sourceContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx = detachContext(ctx)
+	if o.sourceTimeout > 0 {
+		return context.WithTimeout(ctx, o.sourceTimeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// item 单个 key（field）的查询结果，data 来自缓存，val 来自回源
+type item struct {
+	data   []byte
+	val    interface{}
+	status Status
+	// err 回源失败的错误
+	err error
+}
+
 type _Fetcher struct {
-	ca  *CacheAside
-	opt *Option
-	sfg singleflight.Group
+	ca        *CacheAside
+	opt       *Option
+	sfg       flightGroup
+	refresher *refresher
+	limiter   *limiter
+	delayer   *delayer
+}
+
+// Close 立即执行尚未到期的延迟删除，并等待进行中的任务完成；后台刷新由 CacheAside.Close 停止
+func (_f *_Fetcher) Close(ctx context.Context) error {
+	return _f.delayer.close(ctx)
+}
+
+// delDelayed 延迟 delay 后再次执行删除，失败时重试，最终失败交由 delayedDelErrHandler 处理
+func (_f *_Fetcher) delDelayed(ctx context.Context, delay time.Duration, keys, fields []string,
+	del func(ctx context.Context) error) {
+	ok := _f.delayer.schedule(detachContext(ctx), delay, func(ctx context.Context) {
+		err := retry(ctx, _f.opt.delayedDelAttempts, _f.opt.delayedDelBackoff, del)
+		if err != nil && _f.opt.delayedDelErrHandler() != nil {
+			_f.opt.delayedDelErrHandler()(ctx, fmt.Errorf("cacheaside: delayed delete error:%w", err), keys, fields)
+		}
+	})
+	if !ok && _f.opt.delayedDelErrHandler() != nil {
+		_f.opt.delayedDelErrHandler()(ctx, errors.New("cacheaside: delayed delete error:fetcher closed"), keys, fields)
+	}
 }
 
 type Fetcher struct {
 	*_Fetcher
-	fetchSource FetchSource
-	genCacheKey GenCacheKey
+	fetchSource        FetchSource
+	fetchSourcePartial FetchSourcePartial
+	genCacheKey        GenCacheKey
 }
 
 type HFetcher struct {
@@ -206,37 +625,121 @@ type HFetcher struct {
 
 func (f *Fetcher) Get(ctx context.Context, key string, res interface{},
 	extra ...interface{}) (bool, error) {
-	return f.mget(ctx, []string{key}, res, extra...)
+	keys, items, ok, err := f.mget(ctx, spanGet, []string{key}, res, extra...)
+	if err != nil {
+		return false, err
+	}
+	return ok, itemsErr(keys, items)
 }
 
 func (f *Fetcher) MGet(ctx context.Context, keys []string, res interface{},
 	extra ...interface{}) error {
-	_, err := f.mget(ctx, keys, res, extra...)
-	return err
+	keys, items, _, err := f.mget(ctx, spanMGet, keys, res, extra...)
+	if err != nil {
+		return err
+	}
+	return itemsErr(keys, items)
 }
 
-func (f *Fetcher) mget(ctx context.Context, keys []string, res interface{},
-	extra ...interface{}) (bool, error) {
-	if err := f.check(); err != nil {
-		return false, err
+// MGetWithStatus 同 MGet，同时返回与 keys 一一对应的查询状态，可区分空值缓存（StatusNegativeHit）与未查询（StatusMiss）
+func (f *Fetcher) MGetWithStatus(ctx context.Context, keys []string, res interface{},
+	extra ...interface{}) ([]Status, error) {
+	keys, items, _, err := f.mget(ctx, spanMGet, keys, res, extra...)
+	if err != nil {
+		return nil, err
 	}
+	return statuses(keys, items), itemsErr(keys, items)
+}
 
-	var tmpKeys []string
-	for _, key := range keys {
-		tmpKeys = append(tmpKeys, fmt.Sprintf(keyFormat, f.ca.namespance, key))
+// MGetResults 同 MGet，单个 key 回源失败时不返回错误，而是记录在与 keys 一一对应的查询结果中，其余 key 正常返回
+func (f *Fetcher) MGetResults(ctx context.Context, keys []string, res interface{},
+	extra ...interface{}) ([]*MGetResult, error) {
+	if rt := reflect.TypeOf(res); rt == nil || rt.Kind() != reflect.Ptr || rt.Elem().Kind() != reflect.Slice ||
+		rt.Elem().Elem().Kind() != reflect.Ptr {
+		return nil, errors.New("cacheaside: res must be pointer to slice of pointers")
 	}
-	keys = tmpKeys
+	cacheKeys, items, _, err := f.mget(ctx, spanMGet, keys, res, extra...)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*MGetResult, len(keys))
+	resVal := reflect.Indirect(reflect.ValueOf(res))
+	for i, key := range keys {
+		r := &MGetResult{Key: key, Status: StatusMiss}
+		if it, ok := items[cacheKeys[i]]; ok {
+			r.Status, r.Err = it.status, it.err
+		}
+		if v := resVal.Index(i); !v.IsNil() {
+			r.Val = v.Interface()
+		}
+		results[i] = r
+	}
+	return results, nil
+}
 
+// mget 返回带 namespace 的 keys 与查询结果，回源失败的 key 记录在查询结果中
+func (f *Fetcher) mget(ctx context.Context, spanName string, keys []string, res interface{},
+	extra ...interface{}) (_ []string, items map[string]*item, _ bool, err error) {
+	ctx, span := f.startReadSpan(ctx, spanName, len(keys))
+	defer func() {
+		endReadSpan(span, keys, items, err)
+	}()
+	if err := f.check(); err != nil {
+		return nil, nil, false, err
+	}
+	ns, err := f.namespace(ctx)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	keys = f.cacheKeys(ns, keys)
 	resType, resVal, err := f.resRelVal(len(keys), res)
 	if err != nil {
-		return false, err
+		return nil, nil, false, err
+	}
+	items, err = f.fetch(ctx, ns, keys, f.source(), extra...)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	_, decodeSpan := f.startSpan(ctx, spanDecode, Attr{Key: attrKeyCount, Value: len(keys)})
+	ok, err := f.merge(keys, items, resType, resVal)
+	decodeSpan.End(err)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	return keys, items, ok, nil
+}
+
+// source 返回回源函数，FetchSource 统一转换为 FetchSourcePartial
+func (f *Fetcher) source() FetchSourcePartial {
+	if f.fetchSourcePartial != nil {
+		return f.fetchSourcePartial
 	}
+	return partialSource(f.fetchSource)
+}
+
+func partialSource(fetchSource FetchSource) FetchSourcePartial {
+	return func(ctx context.Context, keys []string, extra ...interface{}) ([]interface{}, map[string]error, error) {
+		vals, err := fetchSource(ctx, keys, extra...)
+		return vals, nil, err
+	}
+}
 
-	existM, err := f.ca.cache.MGet(ctx, keys...)
+// fetch 查询缓存（keys 已带 namespace），未命中的 key 通过 fetchSource 回源并回写缓存
+func (f *Fetcher) fetch(ctx context.Context, ns string, keys []string, fetchSource FetchSourcePartial,
+	extra ...interface{}) (map[string]*item, error) {
+	var (
+		existM map[string][]byte
+		err    error
+	)
+	// 缓存不可用（如熔断打开）时跳过读写缓存
+	available := cacheAvailable(f.ca.cache)
+	if available {
+		existM, err = f.cacheMGet(ctx, keys)
+	}
 	if err != nil {
 		err = fmt.Errorf("cacheaside: cache.MGet error:%w", err)
 		if f.opt.strategy() != StrategyCacheFailBackToSource {
-			return false, err
+			return nil, err
 		}
 		if f.opt.cacheGetErrHandler() != nil {
 			f.opt.cacheGetErrHandler()(ctx, err, keys, nil, extra...)
@@ -245,63 +748,268 @@ func (f *Fetcher) mget(ctx context.Context, keys []string, res interface{},
 	if f.opt.log != nil {
 		f.opt.log.Debugf(ctx, "cacheaside: mget hit %d", len(existM))
 	}
+	items, staleKeys, expired := f.unwrap(existM)
+	missKeys := missKeys(keys, items)
+	f.count(items, len(missKeys))
 	if f.opt.strategy() == StrategyOnlyUseCache {
-		return f.merge(keys, existM, nil, resType, resVal)
+		return items, nil
+	}
+	if len(staleKeys) > 0 && f.refresher != nil {
+		f.refresh(ctx, ns, staleKeys, fetchSource, extra...)
 	}
 
-	missKVs, missM, err := f.fetchSourceMiss(ctx, keys, existM, extra...)
+	if len(missKeys) == 0 {
+		return items, nil
+	}
+	fill := func(ctx context.Context, keys []string) error {
+		missKVs, errs, err := f.fetchSourceMiss(ctx, ns, keys, fetchSource, extra...)
+		if err != nil {
+			return err
+		}
+		for key, err := range errs {
+			items[key] = &item{status: StatusMiss, err: err}
+		}
+		if len(missKVs) > 0 && available {
+			err = f.cacheMSet(ctx, f.opt.ttl, missKVs)
+			if err != nil && f.opt.cacheSetErrHandler() != nil {
+				err = f.opt.cacheSetErrHandler()(ctx,
+					fmt.Errorf("cacheaside: cache.MSet error:%w", err), keys, nil, extra...)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		loaded(items, missKVs)
+		f.loads(len(missKVs))
+		return nil
+	}
+	if f.opt.locker == nil || !available {
+		err = fill(ctx, missKeys)
+	} else {
+		err = f.fillWithLock(ctx, missKeys, items, func(key string) string {
+			return fmt.Sprintf(lockKeyFormat, key)
+		}, func(ctx context.Context, keys []string) (map[string]*item, error) {
+			existM, err := f.cacheMGet(ctx, keys)
+			if err != nil {
+				return nil, fmt.Errorf("cacheaside: cache.MGet error:%w", err)
+			}
+			items, _, _ := f.unwrap(existM)
+			return items, nil
+		}, fill)
+	}
 	if err != nil {
-		return false, err
+		return nil, err
 	}
-	err = f.ca.cache.MSet(ctx, f.opt.ttl, missKVs...)
-	if err != nil && f.opt.cacheSetErrHandler() != nil {
-		err = f.opt.cacheSetErrHandler()(ctx,
-			fmt.Errorf("cacheaside: cache.MSet error:%w", err), keys, nil, extra...)
+	if f.opt.limitPolicy == LimitPolicyServeStale {
+		for key, it := range items {
+			if s, ok := expired[key]; ok && errors.Is(it.err, ErrSourceLimited) {
+				items[key] = s
+			}
+		}
+	}
+	return items, nil
+}
+
+// Set 写入缓存，key 由 genCacheKey 生成
+func (f *Fetcher) Set(ctx context.Context, value interface{}) error {
+	return f.MSet(ctx, value)
+}
+
+// MSet 写入多个值到缓存（write-through），key 由 genCacheKey 生成，编码、过期时间与回源回写一致
+func (f *Fetcher) MSet(ctx context.Context, values ...interface{}) error {
+	if err := f.check(); err != nil {
+		return err
+	}
+	return f.mset(ctx, values)
+}
+
+func (f *Fetcher) mset(ctx context.Context, values []interface{}) error {
+	ns, err := f.namespace(ctx)
+	if err != nil {
+		return err
+	}
+	keys, key2Val, err := f.genKeys(ctx, values, func(ctx context.Context, v interface{}) (string, error) {
+		key, err := f.genCacheKey(ctx, v)
 		if err != nil {
-			return false, err
+			return "", fmt.Errorf("cacheaside: Fetcher.genCacheKey error:%w", err)
 		}
+		return f.cacheKey(ns, key), nil
+	})
+	if err != nil || len(keys) == 0 {
+		return err
+	}
+	kvs, err := f.kvs(ctx, keys, key2Val, f.opt.now(), false, f.originKeyFunc(ns))
+	if err != nil {
+		return err
 	}
-	return f.merge(keys, existM, missM, resType, resVal)
+	if err = f.cacheMSet(ctx, f.opt.ttl, kvs); err != nil {
+		return fmt.Errorf("cacheaside: cache.MSet error:%w", err)
+	}
+	return nil
 }
 
 func (f *Fetcher) MDel(ctx context.Context, keys ...string) error {
 	if err := f.check(); err != nil {
 		return err
 	}
-	var tmpKeys []string
+	ns, err := f.namespace(ctx)
+	if err != nil {
+		return err
+	}
+	return f.ca.cache.MDel(ctx, f.cacheKeys(ns, keys)...)
+}
+
+// MDelDelayed 延迟双删：立即删除 keys，并在 delay 后再次删除，避免删除后读请求回填旧值
+func (f *Fetcher) MDelDelayed(ctx context.Context, delay time.Duration, keys ...string) error {
+	if err := f.check(); err != nil {
+		return err
+	}
+	ns, err := f.namespace(ctx)
+	if err != nil {
+		return err
+	}
+	return f.mdelDelayed(ctx, delay, f.cacheKeys(ns, keys))
+}
+
+func (f *Fetcher) mdelDelayed(ctx context.Context, delay time.Duration, keys []string) error {
+	del := func(ctx context.Context) error {
+		return f.ca.cache.MDel(ctx, keys...)
+	}
+	if err := del(ctx); err != nil {
+		return err
+	}
+	f.delDelayed(ctx, delay, keys, nil, del)
+	return nil
+}
+
+// namespace 返回当前生效的 namespace，开启版本号时为 namespace:gen
+func (_f *_Fetcher) namespace(ctx context.Context) (string, error) {
+	if _f.ca.gen == nil {
+		return _f.ca.namespance, nil
+	}
+	gen, err := _f.ca.gen.get(ctx)
+	if err != nil {
+		return "", fmt.Errorf("cacheaside: namespace generation error:%w", err)
+	}
+	return fmt.Sprintf(genNamespaceFormat, _f.ca.namespance, gen), nil
+}
+
+// hashKey 返回带 namespace 的 hash key
+func (_f *_Fetcher) hashKey(ctx context.Context, key string) (string, error) {
+	ns, err := _f.namespace(ctx)
+	if err != nil {
+		return "", err
+	}
+	return _f.cacheKey(ns, key), nil
+}
+
+func (_f *_Fetcher) cacheKeys(ns string, keys []string) []string {
+	tmpKeys := make([]string, 0, len(keys))
 	for _, key := range keys {
-		tmpKeys = append(tmpKeys, fmt.Sprintf(keyFormat, f.ca.namespance, key))
+		tmpKeys = append(tmpKeys, _f.cacheKey(ns, key))
+	}
+	return tmpKeys
+}
+
+func (_f *_Fetcher) cacheKey(ns, key string) string {
+	if _f.opt.hashTag != nil {
+		if tag := _f.opt.hashTag(key); tag != "" {
+			return fmt.Sprintf(hashTagKeyFormat, ns, tag, key)
+		}
+	}
+	return fmt.Sprintf(keyFormat, ns, key)
+}
+
+// originKeyFunc 返回将 ns 下的缓存 key 还原为原始 key 的函数，ttlFunc 为空时无需还原
+func (_f *_Fetcher) originKeyFunc(ns string) func(cacheKey string) string {
+	if _f.opt.ttlFunc == nil {
+		return nil
+	}
+	return func(cacheKey string) string {
+		return _f.originKey(ns, cacheKey)
 	}
-	keys = tmpKeys
-	return f.ca.cache.MDel(ctx, keys...)
+}
+
+// originKey 为 cacheKey 的逆运算
+func (_f *_Fetcher) originKey(ns, cacheKey string) string {
+	key := strings.TrimPrefix(cacheKey, fmt.Sprintf(keyFormat, ns, ""))
+	if _f.opt.hashTag == nil || !strings.HasPrefix(key, "{") {
+		return key
+	}
+	// tag 中可能含有 }，逐个尝试
+	for i := 1; i < len(key); i++ {
+		if key[i] == '}' && _f.opt.hashTag(key[i+1:]) == key[1:i] {
+			return key[i+1:]
+		}
+	}
+	return key
 }
 
 func (hf *HFetcher) HGet(ctx context.Context, key, field string, res interface{},
 	extra ...interface{}) (bool, error) {
-	return hf.hmGet(ctx, key, []string{field}, res, extra...)
+	ok, _, err := hf.hmGet(ctx, spanHGet, key, []string{field}, res, extra...)
+	return ok, err
 }
 
 func (hf *HFetcher) HMGet(ctx context.Context, key string, fields []string, res interface{},
 	extra ...interface{}) error {
-	_, err := hf.hmGet(ctx, key, fields, res, extra...)
+	_, _, err := hf.hmGet(ctx, spanHMGet, key, fields, res, extra...)
 	return err
 }
 
-func (hf *HFetcher) hmGet(ctx context.Context, key string, fields []string, res interface{},
-	extra ...interface{}) (bool, error) {
+// HMGetWithStatus 同 HMGet，同时返回与 fields 一一对应的查询状态
+func (hf *HFetcher) HMGetWithStatus(ctx context.Context, key string, fields []string, res interface{},
+	extra ...interface{}) ([]Status, error) {
+	_, ss, err := hf.hmGet(ctx, spanHMGet, key, fields, res, extra...)
+	return ss, err
+}
+
+func (hf *HFetcher) hmGet(ctx context.Context, spanName string, key string, fields []string, res interface{},
+	extra ...interface{}) (_ bool, _ []Status, err error) {
+	var items map[string]*item
+	ctx, span := hf.startReadSpan(ctx, spanName, len(fields))
+	defer func() {
+		endReadSpan(span, fields, items, err)
+	}()
 	if err := hf.check(); err != nil {
-		return false, err
+		return false, nil, err
 	}
 	tmpResType, tmpResVal, err := hf.resRelVal(len(fields), res)
 	if err != nil {
-		return false, err
+		return false, nil, err
+	}
+	key, err = hf.hashKey(ctx, key)
+	if err != nil {
+		return false, nil, err
+	}
+	items, err = hf.fetch(ctx, key, fields, hf.fetchSource, extra...)
+	if err != nil {
+		return false, nil, err
+	}
+	_, decodeSpan := hf.startSpan(ctx, spanDecode, Attr{Key: attrKeyCount, Value: len(fields)})
+	ok, err := hf.merge(fields, items, tmpResType, tmpResVal)
+	decodeSpan.End(err)
+	if err != nil {
+		return false, nil, err
+	}
+	return ok, statuses(fields, items), nil
+}
+
+// fetch 查询缓存 hash（key 已带 namespace），未命中的 field 通过 fetchSource 回源并回写缓存
+func (hf *HFetcher) fetch(ctx context.Context, key string, fields []string, fetchSource FetchSourceHash,
+	extra ...interface{}) (map[string]*item, error) {
+	var (
+		existM map[string][]byte
+		err    error
+	)
+	available := cacheAvailable(hf.ca.hcache)
+	if available {
+		existM, err = hf.cacheHMGet(ctx, key, fields)
 	}
-	key = fmt.Sprintf(keyFormat, hf.ca.namespance, key)
-	existM, err := hf.ca.hcache.HMGet(ctx, key, fields...)
 	if err != nil {
 		err = fmt.Errorf("cacheaside: cache.HMGet error:%w", err)
 		if hf.opt.strategy() != StrategyCacheFailBackToSource {
-			return false, err
+			return nil, err
 		}
 		if hf.opt.cacheGetErrHandler() != nil {
 			hf.opt.cacheGetErrHandler()(ctx, err, []string{key}, fields, extra...)
@@ -310,31 +1018,197 @@ func (hf *HFetcher) hmGet(ctx context.Context, key string, fields []string, res
 	if hf.opt.log != nil {
 		hf.opt.log.Debugf(ctx, "cacheaside: hmget hit %d", len(existM))
 	}
+	items, staleFields, expired := hf.unwrap(existM)
+	missFields := missKeys(fields, items)
+	hf.count(items, len(missFields))
 	if hf.opt.strategy() == StrategyOnlyUseCache {
-		return hf.merge(fields, existM, nil, tmpResType, tmpResVal)
+		return items, nil
 	}
-	missKVs, missM, err := hf.fetchSourceMiss(ctx, key, fields, existM, extra...)
-	if err != nil {
-		return false, err
+	if len(staleFields) > 0 && hf.refresher != nil {
+		hf.refresh(ctx, key, staleFields, fetchSource, extra...)
 	}
-	if len(missKVs) > 0 {
-		err = hf.ca.hcache.HMSet(ctx, key, hf.opt.ttl, missKVs...)
-		if err != nil && hf.opt.cacheSetErrHandler() != nil {
-			err = hf.opt.cacheSetErrHandler()(ctx, fmt.Errorf("cacheaside: cache.HMSet error:%w", err),
-				[]string{key}, fields, extra...)
+
+	if len(missFields) == 0 {
+		return items, nil
+	}
+	fill := func(ctx context.Context, fields []string) error {
+		missKVs, err := hf.fetchSourceMiss(ctx, key, fields, fetchSource, extra...)
+		if err != nil {
+			return err
+		}
+		if len(missKVs) > 0 && available {
+			err = hf.cacheHMSet(ctx, key, hf.opt.cacheTTL(hf.opt.jitter(hf.opt.ttl)), missKVs)
+			if err != nil && hf.opt.cacheSetErrHandler() != nil {
+				err = hf.opt.cacheSetErrHandler()(ctx, fmt.Errorf("cacheaside: cache.HMSet error:%w", err),
+					[]string{key}, fields, extra...)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		loaded(items, missKVs)
+		hf.loads(len(missKVs))
+		return nil
+	}
+	if hf.opt.locker == nil || !available {
+		err = fill(ctx, missFields)
+	} else {
+		err = hf.fillWithLock(ctx, missFields, items, func(field string) string {
+			return fmt.Sprintf(hashLockKeyFormat, key, field)
+		}, func(ctx context.Context, fields []string) (map[string]*item, error) {
+			existM, err := hf.cacheHMGet(ctx, key, fields)
 			if err != nil {
-				return false, err
+				return nil, fmt.Errorf("cacheaside: cache.HMGet error:%w", err)
 			}
+			items, _, _ := hf.unwrap(existM)
+			return items, nil
+		}, fill)
+	}
+	if err != nil && hf.opt.limitPolicy == LimitPolicyServeStale && errors.Is(err, ErrSourceLimited) {
+		// hash 回源失败不区分 field，所有未命中的 field 均有旧数据时返回旧数据
+		stale := make(map[string]*item, len(missFields))
+		for _, field := range missFields {
+			if _, ok := items[field]; ok {
+				continue
+			}
+			if s, ok := expired[field]; ok {
+				stale[field] = s
+			}
+		}
+		if len(stale) == len(missKeys(missFields, items)) {
+			for field, s := range stale {
+				items[field] = s
+			}
+			err = nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// cacheAvailable c 实现 cache.Availabler 时返回其可用性，否则始终可用
+func cacheAvailable(c interface{}) bool {
+	a, ok := c.(cache.Availabler)
+	return !ok || a.Available()
+}
+
+// missKeys 返回 items 中不存在的 keys
+func missKeys(keys []string, items map[string]*item) []string {
+	var miss []string
+	for _, key := range keys {
+		if _, ok := items[key]; ok {
+			continue
 		}
+		miss = append(miss, key)
+	}
+	return miss
+}
+
+// loaded 将回源结果写入 items
+func loaded(items map[string]*item, kvs []*cache.KV) {
+	for _, kv := range kvs {
+		if kv.Val != nil {
+			items[kv.Key] = &item{val: kv.Val, status: StatusLoaded}
+		} else {
+			items[kv.Key] = &item{status: StatusNotFound}
+		}
+	}
+}
+
+// HSet 写入 hash 缓存，field 由 genCacheHashField 生成
+func (hf *HFetcher) HSet(ctx context.Context, key string, value interface{}) error {
+	return hf.HMSet(ctx, key, value)
+}
+
+// HMSet 写入多个值到 hash 缓存（write-through），field 由 genCacheHashField 生成，编码、过期时间与回源回写一致
+func (hf *HFetcher) HMSet(ctx context.Context, key string, values ...interface{}) error {
+	if err := hf.check(); err != nil {
+		return err
+	}
+	return hf.hmSet(ctx, key, values)
+}
+
+func (hf *HFetcher) hmSet(ctx context.Context, key string, values []interface{}) error {
+	fields, field2Val, err := hf.genKeys(ctx, values, func(ctx context.Context, v interface{}) (string, error) {
+		field, err := hf.genCacheHashField(ctx, v)
+		if err != nil {
+			return "", fmt.Errorf("cacheaside: HFetcher.genCacheHashField error:%w", err)
+		}
+		return field, nil
+	})
+	if err != nil || len(fields) == 0 {
+		return err
+	}
+	kvs, err := hf.kvs(ctx, fields, field2Val, hf.opt.now(), hf.opt.ttlFunc != nil, nil)
+	if err != nil {
+		return err
+	}
+	key, err = hf.hashKey(ctx, key)
+	if err != nil {
+		return err
+	}
+	if err = hf.cacheHMSet(ctx, key, hf.opt.cacheTTL(hf.opt.jitter(hf.opt.ttl)), kvs); err != nil {
+		return fmt.Errorf("cacheaside: cache.HMSet error:%w", err)
+	}
+	return nil
+}
+
+// HMDelDelayed 延迟双删 hash 多个 field：立即删除，并在 delay 后再次删除
+func (hf *HFetcher) HMDelDelayed(ctx context.Context, delay time.Duration, key string, fields ...string) error {
+	if err := hf.check(); err != nil {
+		return err
+	}
+	key, err := hf.hashKey(ctx, key)
+	if err != nil {
+		return err
+	}
+	return hf.hmDelDelayed(ctx, delay, key, fields)
+}
+
+// HDelDelayed 延迟双删 hash key：立即删除，并在 delay 后再次删除
+func (hf *HFetcher) HDelDelayed(ctx context.Context, delay time.Duration, key string) error {
+	if err := hf.check(); err != nil {
+		return err
+	}
+	key, err := hf.hashKey(ctx, key)
+	if err != nil {
+		return err
+	}
+	return hf.hDelDelayed(ctx, delay, key)
+}
+
+func (hf *HFetcher) hmDelDelayed(ctx context.Context, delay time.Duration, key string, fields []string) error {
+	del := func(ctx context.Context) error {
+		return hf.ca.hcache.HMDel(ctx, key, fields...)
+	}
+	if err := del(ctx); err != nil {
+		return err
+	}
+	hf.delDelayed(ctx, delay, []string{key}, fields, del)
+	return nil
+}
+
+func (hf *HFetcher) hDelDelayed(ctx context.Context, delay time.Duration, key string) error {
+	del := func(ctx context.Context) error {
+		return hf.ca.hcache.HDel(ctx, key)
+	}
+	if err := del(ctx); err != nil {
+		return err
 	}
-	return hf.merge(fields, existM, missM, tmpResType, tmpResVal)
+	hf.delDelayed(ctx, delay, []string{key}, nil, del)
+	return nil
 }
 
 func (hf *HFetcher) HMDel(ctx context.Context, key string, fields ...string) error {
 	if err := hf.check(); err != nil {
 		return err
 	}
-	key = fmt.Sprintf(keyFormat, hf.ca.namespance, key)
+	key, err := hf.hashKey(ctx, key)
+	if err != nil {
+		return err
+	}
 	return hf.ca.hcache.HMDel(ctx, key, fields...)
 }
 
@@ -342,147 +1216,318 @@ func (hf *HFetcher) HDel(ctx context.Context, key string) error {
 	if err := hf.check(); err != nil {
 		return err
 	}
-	key = fmt.Sprintf(keyFormat, hf.ca.namespance, key)
+	key, err := hf.hashKey(ctx, key)
+	if err != nil {
+		return err
+	}
 	return hf.ca.hcache.HDel(ctx, key)
 }
 
-func (f *Fetcher) fetchSourceMiss(ctx context.Context, keys []string,
-	existM map[string][]byte, extra ...interface{}) ([]*cache.KV, map[string]interface{}, error) {
-	var missKeys []string
-	for _, key := range keys {
-		if _, ok := existM[key]; ok {
-			continue
-		}
-		missKeys = append(missKeys, key)
-	}
-	if len(missKeys) == 0 {
-		return nil, nil, nil
-	}
+// fetchSourceMiss 回源查询 missKeys，返回待回写缓存的 kvs 与回源失败的 key 的错误
+func (f *Fetcher) fetchSourceMiss(ctx context.Context, ns string, missKeys []string,
+	fetchSource FetchSourcePartial, extra ...interface{}) ([]*cache.KV, map[string]error, error) {
+	missKeys = append([]string(nil), missKeys...)
 	sort.Strings(missKeys)
-	vals, err, _ := f.sfg.Do(fmt.Sprintf(keyFormat, f.ca.namespance, strings.Join(missKeys, ",")),
-		func() (interface{}, error) {
-			v, e := f.fetchSource(ctx, missKeys, extra...)
-			if e != nil {
-				return nil, fmt.Errorf("cacheaside: Fetcher.fetchSource error:%w", e)
+	start := f.opt.now()
+	load := func(ctx context.Context, keys []string) (map[string]interface{}, map[string]error, error) {
+		release, err := f.limiter.acquire(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer release()
+		callStart := time.Now()
+		ctx, span := f.startSpan(ctx, spanSource, Attr{Key: attrKeyCount, Value: len(keys)})
+		vals, errs, err := fetchSource(ctx, keys, extra...)
+		span.End(err)
+		f.observe(OpSource, callStart, err)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cacheaside: Fetcher.fetchSource error:%w", err)
+		}
+		m := make(map[string]interface{}, len(vals))
+		for _, v := range vals {
+			key, err := f.genCacheKey(ctx, v, extra...)
+			if err != nil {
+				return nil, nil, fmt.Errorf("cacheaside: Fetcher.genCacheKey error:%w", err)
 			}
-			return v, nil
-		})
+			m[f.cacheKey(ns, key)] = v
+		}
+		return m, errs, nil
+	}
+	// 已在回源中的 key 等待其结果，其余 key 合并后分批回源
+	waitCtx, span := f.startSpan(ctx, spanWait, Attr{Key: attrKeyCount, Value: len(missKeys)})
+	missM, errs, err := f.sfg.do(waitCtx, "", missKeys, func(keys []string) (map[string]interface{}, map[string]error, error) {
+		ctx, cancel := f.opt.sourceContext(waitCtx)
+		defer cancel()
+		return f.sourceChunks(ctx, keys, load)
+	})
+	span.End(err)
 	if err != nil {
 		return nil, nil, err
 	}
-	missM := make(map[string]interface{})
-	for _, v := range vals.([]interface{}) {
-		key, err := f.genCacheKey(ctx, v, extra...)
-		if err != nil {
-			return nil, nil, fmt.Errorf("cacheaside: Fetcher.genCacheKey error:%w", err)
+	if len(errs) > 0 {
+		okKeys := make([]string, 0, len(missKeys))
+		for _, key := range missKeys {
+			if _, ok := errs[key]; !ok {
+				okKeys = append(okKeys, key)
+			}
 		}
-		missM[fmt.Sprintf(keyFormat, f.ca.namespance, key)] = v
+		missKeys = okKeys
 	}
 
-	var missKVs []*cache.KV
-	for _, key := range missKeys {
-		var data []byte
-		val := missM[key]
-		if val != nil {
-			data, err = f.ca.code.Encode(val)
+	kvs, err := f.kvs(ctx, missKeys, missM, start, false, f.originKeyFunc(ns))
+	return kvs, errs, err
+}
+
+// fetchSourceMiss 回源查询 hash 中的 missFields，返回待回写缓存的 kvs
+func (hf *HFetcher) fetchSourceMiss(ctx context.Context, key string, missFields []string,
+	fetchSource FetchSourceHash, extra ...interface{}) ([]*cache.KV, error) {
+	missFields = append([]string(nil), missFields...)
+	sort.Strings(missFields)
+	start := hf.opt.now()
+	load := func(ctx context.Context, fields []string) (map[string]interface{}, map[string]error, error) {
+		release, err := hf.limiter.acquire(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer release()
+		callStart := time.Now()
+		ctx, span := hf.startSpan(ctx, spanSource, Attr{Key: attrKeyCount, Value: len(fields)})
+		vals, err := fetchSource(ctx, key, fields, extra...)
+		span.End(err)
+		hf.observe(OpSource, callStart, err)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cacheaside: HFetcher.fetchSource error:%w", err)
+		}
+		m := make(map[string]interface{}, len(vals))
+		for _, v := range vals {
+			field, err := hf.genCacheHashField(ctx, v, extra...)
 			if err != nil {
-				return nil, nil, err
+				return nil, nil, fmt.Errorf("cacheaside: HFetcher.genCacheHashField error:%w", err)
 			}
+			m[field] = v
 		}
-		missKVs = append(missKVs, &cache.KV{
-			Key:  key,
-			Val:  missM[key],
-			Data: data,
-		})
+		return m, nil, nil
+	}
+	waitCtx, span := hf.startSpan(ctx, spanWait, Attr{Key: attrKeyCount, Value: len(missFields)})
+	missM, errs, err := hf.sfg.do(waitCtx, key, missFields, func(fields []string) (map[string]interface{}, map[string]error, error) {
+		ctx, cancel := hf.opt.sourceContext(waitCtx)
+		defer cancel()
+		return hf.sourceChunks(ctx, fields, load)
+	})
+	span.End(err)
+	if err != nil {
+		return nil, err
 	}
-	return missKVs, missM, nil
+	for _, err := range errs {
+		return nil, err
+	}
+
+	// hash 只能按 key 设置过期时间，field 的过期时间记录在封装数据中
+	return hf.kvs(ctx, missFields, missM, start, hf.opt.ttlFunc != nil, nil)
 }
 
-func (hf *HFetcher) fetchSourceMiss(ctx context.Context, key string, fields []string,
-	existM map[string][]byte, extra ...interface{}) ([]*cache.KV, map[string]interface{}, error) {
-	var missFields []string
-	for _, key := range fields {
-		if _, ok := existM[key]; ok {
-			continue
+// genKeys 生成 values 对应的 key（field），同一 key 以最后一个值为准
+func (_f *_Fetcher) genKeys(ctx context.Context, values []interface{},
+	gen func(ctx context.Context, v interface{}) (string, error)) ([]string, map[string]interface{}, error) {
+	var keys []string
+	key2Val := make(map[string]interface{}, len(values))
+	for _, v := range values {
+		if v == nil {
+			return nil, nil, errors.New("cacheaside: value is nil")
 		}
-		missFields = append(missFields, key)
-	}
-	if len(missFields) == 0 {
-		return nil, nil, nil
-	}
-	sort.Strings(missFields)
-	vals, err, _ := hf.sfg.Do(fmt.Sprintf("[%s]", strings.Join(missFields, ",")),
-		func() (interface{}, error) {
-			v, e := hf.fetchSource(ctx, key, missFields, extra...)
-			if e != nil {
-				return nil, fmt.Errorf("cacheaside: HFetcher.fetchSource error:%w", e)
-			}
-			return v, nil
-		})
-	if err != nil {
-		return nil, nil, err
-	}
-	missM := make(map[string]interface{})
-	for _, v := range vals.([]interface{}) {
-		field, err := hf.genCacheHashField(ctx, v, extra...)
+		key, err := gen(ctx, v)
 		if err != nil {
-			return nil, nil, fmt.Errorf("cacheaside: HFetcher.genCacheHashField error:%w", err)
+			return nil, nil, err
+		}
+		if _, ok := key2Val[key]; !ok {
+			keys = append(keys, key)
 		}
-		missM[field] = v
+		key2Val[key] = v
 	}
+	return keys, key2Val, nil
+}
 
-	var missKVs []*cache.KV
-	for _, field := range missFields {
+// kvs 编码回源结果，生成待回写缓存的 KV，start 为开始回源的时间；
+// originKey 不为空时将缓存 key 还原为原始 key 后计算过期时间
+func (_f *_Fetcher) kvs(ctx context.Context, keys []string, missM map[string]interface{},
+	start time.Time, logicalExpire bool, originKey func(key string) string) (_ []*cache.KV, err error) {
+	_, span := _f.startSpan(ctx, spanEncode, Attr{Key: attrKeyCount, Value: len(keys)})
+	defer func() {
+		span.End(err)
+	}()
+	now := _f.opt.now()
+	delta := now.Sub(start)
+	kvs := make([]*cache.KV, 0, len(keys))
+	for _, key := range keys {
 		var data []byte
-		val := missM[field]
+		val := missM[key]
 		if val != nil {
-			data, err = hf.ca.code.Encode(val)
+			data, err = _f.ca.code.Encode(val)
 			if err != nil {
-				return nil, nil, err
+				return nil, err
 			}
 		}
-		missKVs = append(missKVs, &cache.KV{
-			Key:  field,
-			Val:  missM[field],
-			Data: data,
+		ttlKey := key
+		if originKey != nil {
+			ttlKey = originKey(key)
+		}
+		ttl := _f.opt.kvTTL(ctx, ttlKey, val)
+		cacheTTL := ttl
+		if val != nil {
+			cacheTTL = _f.opt.cacheTTL(ttl)
+		}
+		kvs = append(kvs, &cache.KV{
+			Key:  key,
+			Val:  val,
+			Data: _f.wrap(data, now, delta, ttl, logicalExpire),
+			TTL:  cacheTTL,
 		})
 	}
-	return missKVs, missM, nil
+	return kvs, nil
 }
 
-func (_f *_Fetcher) merge(keys []string, exsitM map[string][]byte, missM map[string]interface{},
-	rt reflect.Type, res reflect.Value) (bool, error) {
-	key2RefVal := make(map[string]reflect.Value)
-	for k, data := range exsitM {
-		if len(data) == 0 {
-			continue
+// refresh 后台回源刷新已软过期的 keys
+func (f *Fetcher) refresh(ctx context.Context, ns string, keys []string, fetchSource FetchSourcePartial,
+	extra ...interface{}) {
+	ctx = detachContext(ctx)
+	sort.Strings(keys)
+	f.refresher.submit(strings.Join(keys, ","), func() {
+		missKVs, errs, err := f.fetchSourceMiss(ctx, ns, keys, fetchSource, extra...)
+		if err != nil {
+			f.warn(ctx, fmt.Errorf("cacheaside: refresh error:%w", err))
+			return
+		}
+		for key, err := range errs {
+			f.warn(ctx, fmt.Errorf("cacheaside: refresh key %s error:%w", key, err))
 		}
-		v := reflect.New(_f.indirectType(rt))
-		err := _f.ca.code.Decode(data, v.Interface())
+		err = f.cacheMSet(ctx, f.opt.ttl, missKVs)
+		if err != nil && f.opt.cacheSetErrHandler() != nil {
+			_ = f.opt.cacheSetErrHandler()(ctx,
+				fmt.Errorf("cacheaside: refresh cache.MSet error:%w", err), keys, nil, extra...)
+		}
+	})
+}
+
+// refresh 后台回源刷新 hash 中已软过期的 fields
+func (hf *HFetcher) refresh(ctx context.Context, key string, fields []string, fetchSource FetchSourceHash,
+	extra ...interface{}) {
+	ctx = detachContext(ctx)
+	sort.Strings(fields)
+	hf.refresher.submit(fmt.Sprintf("%s[%s]", key, strings.Join(fields, ",")), func() {
+		missKVs, err := hf.fetchSourceMiss(ctx, key, fields, fetchSource, extra...)
 		if err != nil {
-			return false, err
+			hf.warn(ctx, fmt.Errorf("cacheaside: refresh error:%w", err))
+			return
+		}
+		if len(missKVs) == 0 {
+			return
 		}
-		// fmt.Printf("1: %s - %s - %v -%t \n",k, string(data), v.Interface(), v.Elem().IsZero())
-		if v.Elem().IsZero() {
+		err = hf.cacheHMSet(ctx, key, hf.opt.cacheTTL(hf.opt.jitter(hf.opt.ttl)), missKVs)
+		if err != nil && hf.opt.cacheSetErrHandler() != nil {
+			_ = hf.opt.cacheSetErrHandler()(ctx, fmt.Errorf("cacheaside: refresh cache.HMSet error:%w", err),
+				[]string{key}, fields, extra...)
+		}
+	})
+}
+
+// unwrap 解析缓存数据，返回结果项及已软过期的 keys，提前过期的 key 视为未命中
+func (_f *_Fetcher) unwrap(existM map[string][]byte) (map[string]*item, []string, map[string]*item) {
+	now := _f.opt.now()
+	items := make(map[string]*item, len(existM))
+	var staleKeys []string
+	// expired 已过期的旧数据，仅 LimitPolicyServeStale 时收集
+	var expired map[string]*item
+	if _f.opt.limitPolicy == LimitPolicyServeStale {
+		expired = make(map[string]*item)
+	}
+	for key, data := range existM {
+		env, ok := decodeEnvelope(data)
+		if !ok {
+			if len(data) == 0 {
+				items[key] = &item{status: StatusNegativeHit}
+			} else {
+				items[key] = &item{data: data, status: StatusHit}
+			}
 			continue
 		}
-		key2RefVal[k] = v
+		if (env.flags&flagExpire != 0 && now.UnixNano() >= env.expire) || _f.opt.expireEarly(now, env) {
+			if expired != nil && env.flags&flagNil == 0 && len(env.data) > 0 {
+				expired[key] = &item{data: env.data, status: StatusStale}
+			}
+			continue
+		}
+		if env.flags&flagNil != 0 || len(env.data) == 0 {
+			items[key] = &item{status: StatusNegativeHit}
+			continue
+		}
+		items[key] = &item{data: env.data, status: StatusHit}
+		if env.flags&flagSoftExpire != 0 && now.UnixNano() >= env.softExpire {
+			staleKeys = append(staleKeys, key)
+		}
+	}
+	return items, staleKeys, expired
+}
+
+// wrap 按选项封装 Coder 编码后的数据，delta 为本次回源耗时，ttl 为该值的过期时间，
+// logicalExpire 为 true 时在封装数据中记录过期时间
+func (_f *_Fetcher) wrap(data []byte, now time.Time, delta time.Duration, ttl *time.Duration,
+	logicalExpire bool) []byte {
+	if data == nil && _f.opt.negativeTTL != nil {
+		env := &envelope{flags: flagNil | flagExpire, expire: now.Add(*ttl).UnixNano()}
+		return env.encode()
+	}
+	env := &envelope{data: data}
+	if _f.opt.softTTL != nil {
+		env.flags |= flagSoftExpire
+		env.softExpire = now.Add(*_f.opt.softTTL).UnixNano()
+	}
+	if ttl != nil && (_f.opt.earlyBeta > 0 || logicalExpire || _f.opt.limitPolicy == LimitPolicyServeStale) {
+		env.flags |= flagExpire
+		env.expire = now.Add(*ttl).UnixNano()
+	}
+	if ttl != nil && _f.opt.earlyBeta > 0 {
+		env.flags |= flagDelta
+		env.delta = int64(delta)
+	}
+	if env.flags == 0 {
+		return data
+	}
+	if data == nil {
+		env.flags |= flagNil
+	}
+	return env.encode()
+}
+
+func (_f *_Fetcher) warn(ctx context.Context, err error) {
+	if _f.opt.log != nil {
+		_f.opt.log.Wranf(ctx, "%v", err)
 	}
+}
+
+func (_f *_Fetcher) merge(keys []string, items map[string]*item,
+	rt reflect.Type, res reflect.Value) (bool, error) {
 	for i, key := range keys {
-		var rv reflect.Value
-		b := false
-		if vt, ok := key2RefVal[key]; ok {
-			rv = vt
-			b = true
-		} else if vt, ok := missM[key]; ok {
-			rv = reflect.ValueOf(vt)
-			b = true
-		}
-		if !b {
+		it, ok := items[key]
+		if !ok {
 			continue
 		}
+		var rv reflect.Value
+		if it.status == StatusLoaded {
+			rv = reflect.ValueOf(it.val)
+		} else {
+			if it.status != StatusHit && it.status != StatusStale {
+				continue
+			}
+			rv = reflect.New(_f.indirectType(rt))
+			err := _f.ca.code.Decode(it.data, rv.Interface())
+			if err != nil {
+				return false, err
+			}
+			if rv.Elem().IsZero() {
+				continue
+			}
+		}
 		if res.Kind() == reflect.Slice {
-			// fmt.Printf("%s-%v-%v\n", key, res.Index(i).Interface(), rv.Interface())
 			res.Index(i).Set(rv)
 		} else {
 			res.Elem().Set(rv.Elem())
@@ -516,7 +1561,7 @@ func (_f *_Fetcher) _check(isCache, isHCache bool) error {
 }
 
 func (f *Fetcher) check() error {
-	if f.fetchSource == nil {
+	if f.fetchSource == nil && f.fetchSourcePartial == nil {
 		return errors.New("cacheaside: fetchSource is nil")
 	}
 	if f.genCacheKey == nil {
@@ -544,6 +1589,12 @@ func (_f *_Fetcher) resRelVal(size int, res interface{}) (reflect.Type, reflect.
 		if tmpResVal.Len() < size {
 			newSlice := reflect.MakeSlice(reflect.SliceOf(tmpResType), size, size)
 			tmpResVal.Set(newSlice)
+		} else {
+			// 清空调用方复用的 slice，未命中的 key 不残留旧值
+			zero := reflect.Zero(tmpResType)
+			for i := 0; i < size; i++ {
+				tmpResVal.Index(i).Set(zero)
+			}
 		}
 	}
 	return tmpResType, tmpResVal, nil
@@ -3,6 +3,7 @@ package cacheaside
 import (
 	"context"
 	"encoding/json"
+    "errors"
     "fmt"
 	"github.com/erkesi/cacheaside/cache"
 	"github.com/erkesi/cacheaside/code"
@@ -340,3 +341,460 @@ func (l *_Logger) Debugf(ctx context.Context, format string, v ...interface{}) {
 func (l *_Logger) Wranf(ctx context.Context, format string, v ...interface{}) {
 	l.t.Logf(format, v...)
 }
+
+func TestEarlyExpiration(t *testing.T) {
+	type User struct {
+		Id   string
+		Name string
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	now := time.Now()
+	cached, _ := json.Marshal(&User{Id: "1", Name: "cached"})
+	env := (&envelope{flags: flagExpire | flagDelta, expire: now.Add(time.Hour).UnixNano(),
+		delta: int64(time.Second), data: cached}).encode()
+
+	mcache := cache.NewMockCacher(ctrl)
+	mcache.EXPECT().MGet(gomock.Any(), "ns$1").Return(map[string][]byte{"ns$1": env}, nil).Times(2)
+	mcache.EXPECT().MSet(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, ttl *time.Duration, kvs ...*cache.KV) error {
+			env, ok := decodeEnvelope(kvs[0].Data)
+			if !ok || env.flags != flagExpire|flagDelta || env.expire != now.Add(2*time.Hour).UnixNano() {
+				t.Fatalf("unexpected envelope %v", env)
+			}
+			return nil
+		})
+
+	clock := now
+	ca := NewCacheAside(&code.Json{}, mcache, "ns")
+	caf := ca.Fetch(func(ctx context.Context, keys []string, extra ...interface{}) ([]interface{}, error) {
+		return []interface{}{&User{Id: "1", Name: "source"}}, nil
+	}, func(ctx context.Context, v interface{}, extra ...interface{}) (string, error) {
+		return v.(*User).Id, nil
+	}, WithTTL(time.Hour), WithEarlyExpiration(1), WithClock(func() time.Time {
+		return clock
+	}))
+
+	// 距过期 1h，回源耗时 1s，几乎不可能提前过期
+	var u User
+	if _, err := caf.Get(context.Background(), "1", &u); err != nil {
+		t.Fatal(err)
+	}
+	if u.Name != "cached" {
+		t.Fatalf("expected cached value, got %v", u)
+	}
+
+	// 已到过期时间，必然提前回源
+	clock = now.Add(time.Hour)
+	u = User{}
+	if _, err := caf.Get(context.Background(), "1", &u); err != nil {
+		t.Fatal(err)
+	}
+	if u.Name != "source" {
+		t.Fatalf("expected source value, got %v", u)
+	}
+}
+
+func TestNegativeTTL(t *testing.T) {
+	type User struct {
+		Id string
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	now := time.Now()
+	negative := (&envelope{flags: flagNil | flagExpire, expire: now.Add(time.Minute).UnixNano()}).encode()
+	expired := (&envelope{flags: flagNil | flagExpire, expire: now.Add(-time.Minute).UnixNano()}).encode()
+
+	mcache := cache.NewMockCacher(ctrl)
+	mcache.EXPECT().MGet(gomock.Any(), "ns$1", "ns$2", "ns$3").Return(
+		map[string][]byte{"ns$1": negative, "ns$3": expired}, nil)
+	mcache.EXPECT().MSet(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, ttl *time.Duration, kvs ...*cache.KV) error {
+			if len(kvs) != 2 {
+				t.Fatalf("unexpected kvs %v", kvs)
+			}
+			for _, kv := range kvs {
+				if kv.TTL == nil || *kv.TTL != time.Minute {
+					t.Fatalf("unexpected ttl %v", kv.TTL)
+				}
+				env, ok := decodeEnvelope(kv.Data)
+				if !ok || env.flags&flagNil == 0 || len(env.data) != 0 {
+					t.Fatalf("unexpected envelope %v", env)
+				}
+			}
+			return nil
+		})
+
+	ca := NewCacheAside(&code.Json{}, mcache, "ns")
+	caf := ca.Fetch(func(ctx context.Context, keys []string, extra ...interface{}) ([]interface{}, error) {
+		if len(keys) != 2 || keys[0] != "ns$2" || keys[1] != "ns$3" {
+			t.Fatalf("unexpected keys %v", keys)
+		}
+		return nil, nil
+	}, func(ctx context.Context, v interface{}, extra ...interface{}) (string, error) {
+		return v.(*User).Id, nil
+	}, WithTTL(time.Hour), WithNegativeTTL(time.Minute))
+
+	var us []*User
+	ss, err := caf.MGetWithStatus(context.Background(), []string{"1", "2", "3"}, &us)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(us) != 3 || us[0] != nil || us[1] != nil || us[2] != nil {
+		t.Fatalf("unexpected users %v", us)
+	}
+	if !reflect.DeepEqual(ss, []Status{StatusNegativeHit, StatusNotFound, StatusNotFound}) {
+		t.Fatalf("unexpected status %v", ss)
+	}
+}
+
+func TestTTLJitterAndFunc(t *testing.T) {
+	type Article struct {
+		Id    string
+		Draft bool
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mcache := cache.NewMockCacher(ctrl)
+	mcache.EXPECT().MGet(gomock.Any(), gomock.Any()).Return(nil, nil)
+	mcache.EXPECT().MSet(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, ttl *time.Duration, kvs ...*cache.KV) error {
+			for _, kv := range kvs {
+				max := 100 * time.Minute
+				if kv.Val.(*Article).Draft {
+					max = 10 * time.Minute
+				}
+				if *kv.TTL < max*9/10 || *kv.TTL > max*11/10 {
+					t.Fatalf("unexpected ttl %v for %s", *kv.TTL, kv.Key)
+				}
+			}
+			return nil
+		})
+
+	ca := NewCacheAside(&code.Json{}, mcache, "ns")
+	caf := ca.Fetch(func(ctx context.Context, keys []string, extra ...interface{}) ([]interface{}, error) {
+		var res []interface{}
+		for i, key := range keys {
+			res = append(res, &Article{Id: strings.TrimPrefix(key, "ns$"), Draft: i%2 == 0})
+		}
+		return res, nil
+	}, func(ctx context.Context, v interface{}, extra ...interface{}) (string, error) {
+		return v.(*Article).Id, nil
+	}, WithTTL(100*time.Minute), WithTTLJitter(0.1), WithTTLFunc(
+		func(ctx context.Context, key string, v interface{}) time.Duration {
+			if v.(*Article).Draft {
+				return 10 * time.Minute
+			}
+			return 0
+		}))
+
+	var as []*Article
+	if err := caf.MGet(context.Background(), []string{"1", "2", "3", "4"}, &as); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHMSet(t *testing.T) {
+	type User struct {
+		Extra map[string]string
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mhcache := cache.NewMockHCacher(ctrl)
+	mhcache.EXPECT().HMSet(gomock.Any(), "ns$1", gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, key string, ttl *time.Duration, kvs ...*cache.KV) error {
+			if *ttl != time.Hour || len(kvs) != 2 || kvs[0].Key != "Name" || kvs[1].Key != "Age" ||
+				string(kvs[0].Data) != `{"Extra":{"Name":"tom"}}` {
+				t.Fatalf("unexpected kvs %v", kvs)
+			}
+			return nil
+		})
+
+	ca := NewHCacheAside(&code.Json{}, mhcache, "ns")
+	caf := ca.HFetch(func(ctx context.Context, key string, fields []string, extra ...interface{}) ([]interface{}, error) {
+		t.Fatal("fetchSource should not be called")
+		return nil, nil
+	}, func(ctx context.Context, v interface{}, extra ...interface{}) (string, error) {
+		for k := range v.(*User).Extra {
+			return k, nil
+		}
+		return "", nil
+	}, WithTTL(time.Hour))
+
+	err := caf.HMSet(context.Background(), "1",
+		&User{Extra: map[string]string{"Name": "tom"}}, &User{Extra: map[string]string{"Age": "20"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInvalidateNamespace(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mcounter := cache.NewMockCounter(ctrl)
+	mcounter.EXPECT().Load(gomock.Any(), "ns:gen").Return(int64(3), nil)
+	mcounter.EXPECT().Incr(gomock.Any(), "ns:gen").Return(int64(4), nil)
+
+	mcache := cache.NewMockCacher(ctrl)
+	gomock.InOrder(
+		mcache.EXPECT().MDel(gomock.Any(), "ns:3$1").Return(nil),
+		mcache.EXPECT().MDel(gomock.Any(), "ns:3$1").Return(nil),
+		mcache.EXPECT().MDel(gomock.Any(), "ns:4$1").Return(nil),
+	)
+
+	ca := NewCacheAside(&code.Json{}, mcache, "ns", WithNamespaceGeneration(mcounter, time.Minute))
+	caf := ca.Fetch(func(ctx context.Context, keys []string, extra ...interface{}) ([]interface{}, error) {
+		return nil, nil
+	}, func(ctx context.Context, v interface{}, extra ...interface{}) (string, error) {
+		return "", nil
+	})
+
+	// 版本号在本地缓存，第二次不再读取 counter
+	for i := 0; i < 2; i++ {
+		if err := caf.MDel(context.Background(), "1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ca.InvalidateNamespace(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := caf.MDel(context.Background(), "1"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNamespaceGenerationCancel(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	loading := make(chan struct{})
+	mcounter := cache.NewMockCounter(ctrl)
+	mcounter.EXPECT().Load(gomock.Any(), "ns:gen").DoAndReturn(func(ctx context.Context, key string) (int64, error) {
+		close(loading)
+		time.Sleep(20 * time.Millisecond)
+		return 3, ctx.Err()
+	})
+
+	g := newGeneration(mcounter, "ns", time.Minute)
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		_, err := g.get(ctx)
+		errs <- err
+	}()
+	<-loading
+	cancel()
+	// 首个调用方取消不影响其他等待方
+	val, err := g.get(context.Background())
+	if err != nil || val != 3 {
+		t.Fatalf("unexpected val %d, err %v", val, err)
+	}
+	if err = <-errs; !errors.Is(err, context.Canceled) {
+		t.Fatalf("unexpected err %v", err)
+	}
+}
+
+func TestFetchPartial(t *testing.T) {
+	type User struct {
+		Id   string
+		Name string
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	bs, _ := json.Marshal(&User{Id: "1", Name: "name1"})
+	mcache := cache.NewMockCacher(ctrl)
+	mcache.EXPECT().MGet(gomock.Any(), "ns$1", "ns$2", "ns$3").Return(map[string][]byte{"ns$1": bs}, nil).Times(3)
+	mcache.EXPECT().MSet(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, ttl *time.Duration, kvs ...*cache.KV) error {
+			if len(kvs) != 1 || kvs[0].Key != "ns$2" {
+				t.Fatalf("unexpected kvs %v", kvs)
+			}
+			return nil
+		}).Times(3)
+
+	sourceErr := fmt.Errorf("db error")
+	ca := NewCacheAside(&code.Json{}, mcache, "ns")
+	caf := ca.FetchPartial(func(ctx context.Context, keys []string, extra ...interface{}) ([]interface{},
+		map[string]error, error) {
+		return []interface{}{&User{Id: "2", Name: "name2"}}, map[string]error{"ns$3": sourceErr}, nil
+	}, func(ctx context.Context, v interface{}, extra ...interface{}) (string, error) {
+		return v.(*User).Id, nil
+	}, WithTTL(time.Hour))
+
+	var us []*User
+	results, err := caf.MGetResults(context.Background(), []string{"1", "2", "3"}, &us)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 || results[0].Status != StatusHit || results[1].Status != StatusLoaded ||
+		results[2].Status != StatusMiss || results[2].Err != sourceErr || results[2].Val != nil {
+		t.Fatalf("unexpected results %v", results)
+	}
+	if results[0].Val.(*User).Name != "name1" || results[1].Val.(*User).Name != "name2" {
+		t.Fatalf("unexpected results %v", results)
+	}
+
+	err = caf.MGet(context.Background(), []string{"1", "2", "3"}, &us)
+	if !errors.Is(err, sourceErr) {
+		t.Fatalf("unexpected err %v", err)
+	}
+
+	// 复用的 slice 中未命中的 key 不残留旧值
+	us = []*User{{Id: "old"}, {Id: "old"}, {Id: "old"}}
+	results, err = caf.MGetResults(context.Background(), []string{"1", "2", "3"}, &us)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if us[2] != nil || results[2].Val != nil || results[2].Err != sourceErr {
+		t.Fatalf("unexpected results %v", results[2])
+	}
+
+	var u User
+	var vals []User
+	for _, res := range []interface{}{&u, &vals, us, nil} {
+		if _, err = caf.MGetResults(context.Background(), []string{"1"}, res); err == nil {
+			t.Fatalf("expected error for res %T", res)
+		}
+	}
+}
+
+func TestCacheReadTimeout(t *testing.T) {
+	type User struct {
+		Id   string
+		Name string
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mcache := cache.NewMockCacher(ctrl)
+	mcache.EXPECT().MGet(gomock.Any(), "ns$1").DoAndReturn(
+		func(ctx context.Context, keys ...string) (map[string][]byte, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+	mcache.EXPECT().MSet(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, ttl *time.Duration, kvs ...*cache.KV) error {
+			if _, ok := ctx.Deadline(); !ok {
+				t.Fatal("write ctx should have deadline")
+			}
+			return nil
+		})
+
+	ca := NewCacheAside(&code.Json{}, mcache, "ns")
+	caf := ca.Fetch(func(ctx context.Context, keys []string, extra ...interface{}) ([]interface{}, error) {
+		return []interface{}{&User{Id: "1", Name: "name1"}}, nil
+	}, func(ctx context.Context, v interface{}, extra ...interface{}) (string, error) {
+		return v.(*User).Id, nil
+	}, WithCacheReadTimeout(20*time.Millisecond), WithCacheWriteTimeout(time.Second))
+
+	var u User
+	ok, err := caf.Get(context.Background(), "1", &u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || u.Name != "name1" {
+		t.Fatalf("unexpected user %v", u)
+	}
+}
+
+func TestCacheUnavailable(t *testing.T) {
+	type User struct {
+		Id   string
+		Name string
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mcache := cache.NewMockCacher(ctrl)
+	mcache.EXPECT().MGet(gomock.Any(), "ns$1").Return(nil, fmt.Errorf("timeout"))
+
+	var loads int
+	breaker := cache.NewBreaker(cache.WithBreakerMinRequests(1), cache.WithBreakerOpenTimeout(time.Minute))
+	ca := NewCacheAside(&code.Json{}, cache.NewBreakerCacher(mcache, breaker), "ns")
+	caf := ca.Fetch(func(ctx context.Context, keys []string, extra ...interface{}) ([]interface{}, error) {
+		loads++
+		return []interface{}{&User{Id: "1", Name: "name1"}}, nil
+	}, func(ctx context.Context, v interface{}, extra ...interface{}) (string, error) {
+		return v.(*User).Id, nil
+	})
+
+	// 第一次查询缓存失败打开熔断，之后跳过缓存直接回源
+	for i := 0; i < 2; i++ {
+		var u User
+		ok, err := caf.Get(context.Background(), "1", &u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok || u.Name != "name1" {
+			t.Fatalf("unexpected user %v", u)
+		}
+	}
+	if loads != 2 || breaker.State() != cache.BreakerOpen {
+		t.Fatalf("unexpected loads %d, state %v", loads, breaker.State())
+	}
+}
+
+func TestHashTag(t *testing.T) {
+	type User struct {
+		Id      string
+		GroupId string
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mcache := cache.NewMockCacher(ctrl)
+	mcache.EXPECT().MGet(gomock.Any(), "ns${g1}1", "ns$2").Return(nil, nil)
+	mcache.EXPECT().MSet(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, ttl *time.Duration, kvs ...*cache.KV) error {
+			keys := map[string]bool{}
+			for _, kv := range kvs {
+				keys[kv.Key] = true
+			}
+			if len(keys) != 2 || !keys["ns${g1}1"] || !keys["ns$2"] {
+				t.Fatalf("unexpected keys %v", keys)
+			}
+			return nil
+		})
+
+	ttlKeys := map[string]bool{}
+	ca := NewCacheAside(&code.Json{}, mcache, "ns")
+	caf := ca.Fetch(func(ctx context.Context, keys []string, extra ...interface{}) ([]interface{}, error) {
+		return []interface{}{&User{Id: "1", GroupId: "g1"}, &User{Id: "2"}}, nil
+	}, func(ctx context.Context, v interface{}, extra ...interface{}) (string, error) {
+		return v.(*User).Id, nil
+	}, WithHashTag(func(key string) string {
+		if key == "1" {
+			return "g1"
+		}
+		return ""
+	}), WithTTLFunc(func(ctx context.Context, key string, v interface{}) time.Duration {
+		ttlKeys[key] = true
+		return 0
+	}))
+
+	var us []*User
+	if err := caf.MGet(context.Background(), []string{"1", "2"}, &us); err != nil {
+		t.Fatal(err)
+	}
+	if len(us) != 2 || us[0] == nil || us[1] == nil {
+		t.Fatalf("unexpected users %v", us)
+	}
+	// ttlFunc 收到原始 key
+	if len(ttlKeys) != 2 || !ttlKeys["1"] || !ttlKeys["2"] {
+		t.Fatalf("unexpected ttl keys %v", ttlKeys)
+	}
+}
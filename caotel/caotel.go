@@ -0,0 +1,66 @@
+package caotel
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/erkesi/cacheaside"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/erkesi/cacheaside"
+
+// Tracer 基于 OpenTelemetry 的 cacheaside.Tracer 实现
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+func NewTracer(tp trace.TracerProvider) *Tracer {
+	return &Tracer{
+		tracer: tp.Tracer(instrumentationName),
+	}
+}
+
+func (t *Tracer) Start(ctx context.Context, name string, attrs ...cacheaside.Attr) (context.Context, cacheaside.Span) {
+	ctx, span := t.tracer.Start(ctx, name, trace.WithAttributes(toAttributes(attrs)...))
+	return ctx, &Span{span: span}
+}
+
+type Span struct {
+	span trace.Span
+}
+
+func (s *Span) SetAttributes(attrs ...cacheaside.Attr) {
+	s.span.SetAttributes(toAttributes(attrs)...)
+}
+
+func (s *Span) End(err error) {
+	if err != nil {
+		s.span.RecordError(err)
+		s.span.SetStatus(codes.Error, err.Error())
+	}
+	s.span.End()
+}
+
+func toAttributes(attrs []cacheaside.Attr) []attribute.KeyValue {
+	kvs := make([]attribute.KeyValue, 0, len(attrs))
+	for _, attr := range attrs {
+		switch v := attr.Value.(type) {
+		case string:
+			kvs = append(kvs, attribute.String(attr.Key, v))
+		case int:
+			kvs = append(kvs, attribute.Int(attr.Key, v))
+		case int64:
+			kvs = append(kvs, attribute.Int64(attr.Key, v))
+		case float64:
+			kvs = append(kvs, attribute.Float64(attr.Key, v))
+		case bool:
+			kvs = append(kvs, attribute.Bool(attr.Key, v))
+		default:
+			kvs = append(kvs, attribute.String(attr.Key, fmt.Sprint(v)))
+		}
+	}
+	return kvs
+}
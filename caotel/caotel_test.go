@@ -0,0 +1,119 @@
+package caotel
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/erkesi/cacheaside"
+	"github.com/erkesi/cacheaside/cache"
+	"github.com/erkesi/cacheaside/code"
+	"github.com/golang/mock/gomock"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type User struct {
+	Id   string
+	Name string
+}
+
+func TestTracer(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mcache := cache.NewMockCacher(ctrl)
+	mcache.EXPECT().MGet(gomock.Any(), "ns$1", "ns$2").Return(map[string][]byte{"ns$1": []byte(`{"Id":"1","Name":"name1"}`)}, nil)
+	mcache.EXPECT().MSet(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+
+	exp := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp))
+	ca := cacheaside.NewCacheAside(&code.Json{}, mcache, "ns")
+	caf := ca.Fetch(func(ctx context.Context, keys []string, extra ...interface{}) ([]interface{}, error) {
+		return []interface{}{&User{Id: "2", Name: "name2"}}, nil
+	}, func(ctx context.Context, v interface{}, extra ...interface{}) (string, error) {
+		return v.(*User).Id, nil
+	}, cacheaside.WithTracer(NewTracer(tp)))
+
+	var us []*User
+	if err := caf.MGet(context.Background(), []string{"1", "2"}, &us); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := exp.GetSpans()
+	byName := make(map[string]tracetest.SpanStub, len(spans))
+	for _, s := range spans {
+		byName[s.Name] = s
+	}
+	root, ok := byName["cacheaside.MGet"]
+	if !ok {
+		t.Fatalf("root span not found, spans %v", spans)
+	}
+	attrs := make(map[attribute.Key]attribute.Value, len(root.Attributes))
+	for _, kv := range root.Attributes {
+		attrs[kv.Key] = kv.Value
+	}
+	if attrs["cacheaside.namespace"].AsString() != "ns" || attrs["cacheaside.key_count"].AsInt64() != 2 ||
+		attrs["cacheaside.hit_count"].AsInt64() != 1 || attrs["cacheaside.strategy"].AsString() == "" {
+		t.Fatalf("unexpected root attributes %v", root.Attributes)
+	}
+	for _, name := range []string{"cacheaside.cache_read", "cacheaside.singleflight_wait", "cacheaside.cache_write",
+		"cacheaside.decode"} {
+		s, ok := byName[name]
+		if !ok {
+			t.Fatalf("span %s not found", name)
+		}
+		if s.Parent.SpanID() != root.SpanContext.SpanID() {
+			t.Fatalf("span %s should be child of root", name)
+		}
+	}
+	wait := byName["cacheaside.singleflight_wait"]
+	for _, name := range []string{"cacheaside.fetch_source", "cacheaside.encode"} {
+		s, ok := byName[name]
+		if !ok {
+			t.Fatalf("span %s not found", name)
+		}
+		if s.SpanContext.TraceID() != root.SpanContext.TraceID() {
+			t.Fatalf("span %s should be in the same trace", name)
+		}
+	}
+	if byName["cacheaside.fetch_source"].Parent.SpanID() != wait.SpanContext.SpanID() {
+		t.Fatal("fetch_source should be child of singleflight_wait")
+	}
+}
+
+func TestTracerError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mcache := cache.NewMockCacher(ctrl)
+	mcache.EXPECT().MGet(gomock.Any(), "ns$1").Return(nil, nil)
+
+	exp := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp))
+	ca := cacheaside.NewCacheAside(&code.Json{}, mcache, "ns")
+	caf := ca.Fetch(func(ctx context.Context, keys []string, extra ...interface{}) ([]interface{}, error) {
+		return nil, errors.New("db down")
+	}, func(ctx context.Context, v interface{}, extra ...interface{}) (string, error) {
+		return v.(*User).Id, nil
+	}, cacheaside.WithTracer(NewTracer(tp)))
+
+	var u User
+	if _, err := caf.Get(context.Background(), "1", &u); err == nil {
+		t.Fatal("expected error")
+	}
+	var source, root bool
+	for _, s := range exp.GetSpans() {
+		switch s.Name {
+		case "cacheaside.fetch_source":
+			source = s.Status.Code == codes.Error && len(s.Events) > 0
+		case "cacheaside.Get":
+			root = s.Status.Code == codes.Error
+		}
+	}
+	if !source || !root {
+		t.Fatalf("error should be recorded, spans %v", exp.GetSpans())
+	}
+}
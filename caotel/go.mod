@@ -0,0 +1,31 @@
+module github.com/erkesi/cacheaside/caotel
+
+go 1.21
+
+// 仓库内开发使用本地模块，依赖方使用 require 中已发布的版本（tag v1.1.0、cache/v1.1.0）
+replace (
+	github.com/erkesi/cacheaside => ../
+	github.com/erkesi/cacheaside/cache => ../cache
+	github.com/erkesi/cacheaside/code => ../code
+)
+
+require (
+	github.com/erkesi/cacheaside v1.1.0
+	github.com/erkesi/cacheaside/cache v1.1.0
+	github.com/erkesi/cacheaside/code v1.0.1
+	github.com/golang/mock v1.6.0
+	go.opentelemetry.io/otel v1.28.0
+	go.opentelemetry.io/otel/sdk v1.28.0
+	go.opentelemetry.io/otel/trace v1.28.0
+)
+
+require (
+	github.com/go-logr/logr v1.4.2 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/vmihailenco/msgpack/v5 v5.3.5 // indirect
+	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
+	go.opentelemetry.io/otel/metric v1.28.0 // indirect
+	golang.org/x/sync v0.1.0 // indirect
+	golang.org/x/sys v0.21.0 // indirect
+)
@@ -3,8 +3,11 @@ module github.com/erkesi/cacheaside/caredis
 go 1.16
 
 require (
-	github.com/erkesi/cacheaside/cache v1.0.1
+	github.com/erkesi/cacheaside/cache v1.1.0
 	github.com/go-redis/redis v6.15.9+incompatible
 	github.com/onsi/ginkgo v1.16.5 // indirect
 	github.com/onsi/gomega v1.24.1 // indirect
 )
+
+// 仓库内开发使用本地 cache 模块，依赖方使用 require 中已发布的版本（tag cache/v1.1.0）
+replace github.com/erkesi/cacheaside/cache => ../cache
@@ -0,0 +1,214 @@
+package caredis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/erkesi/cacheaside/cache"
+	"github.com/go-redis/redis"
+)
+
+// Local 本地缓存，如 memory.Cache
+type Local interface {
+	cache.Cacher
+	cache.HCacher
+	// Clear 清空本地缓存
+	Clear()
+}
+
+// invalidation 失效消息，key 已包含 namespace；Hash 不为空时删除 hash 的 Fields，Fields 为空时删除整个 hash
+type invalidation struct {
+	Keys   []string `json:"keys,omitempty"`
+	Hash   string   `json:"hash,omitempty"`
+	Fields []string `json:"fields,omitempty"`
+}
+
+type InvalidateOption struct {
+	healthCheck   time.Duration
+	retryInterval time.Duration
+	errHandler    func(err error)
+}
+
+type InvalidateOptFn func(opt *InvalidateOption)
+
+// WithInvalidateHealthCheck 订阅连接空闲超过 d 时发送 PING 检查连接，默认 5s
+func WithInvalidateHealthCheck(d time.Duration) InvalidateOptFn {
+	return func(opt *InvalidateOption) {
+		opt.healthCheck = d
+	}
+}
+
+// WithInvalidateRetryInterval 订阅断开后重连的间隔，默认 1s
+func WithInvalidateRetryInterval(d time.Duration) InvalidateOptFn {
+	return func(opt *InvalidateOption) {
+		opt.retryInterval = d
+	}
+}
+
+// WithInvalidateErrHandler 处理订阅断开、消息解析与本地删除的错误
+func WithInvalidateErrHandler(fn func(err error)) InvalidateOptFn {
+	return func(opt *InvalidateOption) {
+		opt.errHandler = fn
+	}
+}
+
+// Invalidator 通过 redis pub/sub 在多个实例间同步本地缓存的失效：
+// Middleware 在删除后发布失效消息，订阅方删除本地缓存中对应的 key（field）；
+// 订阅断开或（重新）订阅成功时可能丢失消息，清空本地缓存
+type Invalidator struct {
+	cli     redis.UniversalClient
+	channel string
+	local   Local
+	opt     *InvalidateOption
+	pubsub  *redis.PubSub
+	done    chan struct{}
+	wg      sync.WaitGroup
+	once    sync.Once
+}
+
+// NewInvalidator 订阅 channel 并在后台处理失效消息，退出前调用 Close；cli 的要求同 NewRedisWrap
+func NewInvalidator(cli redis.UniversalClient, channel string, local Local, opts ...InvalidateOptFn) *Invalidator {
+	opt := &InvalidateOption{
+		healthCheck:   5 * time.Second,
+		retryInterval: time.Second,
+		errHandler:    func(err error) {},
+	}
+	for _, fn := range opts {
+		fn(opt)
+	}
+	i := &Invalidator{
+		cli:     cli,
+		channel: channel,
+		local:   local,
+		opt:     opt,
+		pubsub:  cli.Subscribe(channel),
+		done:    make(chan struct{}),
+	}
+	i.wg.Add(1)
+	go i.subscribe()
+	return i
+}
+
+// Middleware 删除成功后发布失效消息，用于包装 l2 为 redis 的两级缓存：
+// cache.Chain(cache.NewTwoLevelCacher(local, redisWrap, l1TTL), invalidator.Middleware())
+func (i *Invalidator) Middleware() cache.Middleware {
+	return cache.Middleware{
+		MDel: func(next cache.MDelHandler) cache.MDelHandler {
+			return func(ctx context.Context, keys ...string) error {
+				if err := next(ctx, keys...); err != nil {
+					return err
+				}
+				return i.publish(ctx, &invalidation{Keys: keys})
+			}
+		},
+		HDel: func(next cache.HDelHandler) cache.HDelHandler {
+			return func(ctx context.Context, key string) error {
+				if err := next(ctx, key); err != nil {
+					return err
+				}
+				return i.publish(ctx, &invalidation{Hash: key})
+			}
+		},
+		HMDel: func(next cache.HMDelHandler) cache.HMDelHandler {
+			return func(ctx context.Context, key string, fields ...string) error {
+				if err := next(ctx, key, fields...); err != nil {
+					return err
+				}
+				return i.publish(ctx, &invalidation{Hash: key, Fields: fields})
+			}
+		},
+	}
+}
+
+// Close 停止订阅
+func (i *Invalidator) Close() error {
+	var err error
+	i.once.Do(func() {
+		close(i.done)
+		err = i.pubsub.Close()
+		i.wg.Wait()
+	})
+	return err
+}
+
+func (i *Invalidator) publish(ctx context.Context, msg *invalidation) error {
+	if len(msg.Keys) == 0 && msg.Hash == "" {
+		return nil
+	}
+	bs, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if err = withContext(ctx, i.cli).Publish(i.channel, bs).Err(); err != nil {
+		return fmt.Errorf("caredis: publish invalidation error:%w", err)
+	}
+	return nil
+}
+
+func (i *Invalidator) subscribe() {
+	defer i.wg.Done()
+	for {
+		msg, err := i.pubsub.ReceiveTimeout(i.opt.healthCheck)
+		if err != nil {
+			if i.closed() {
+				return
+			}
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				if err = i.pubsub.Ping(); err == nil {
+					continue
+				}
+			}
+			i.opt.errHandler(fmt.Errorf("caredis: receive invalidation error:%w", err))
+			i.local.Clear()
+			select {
+			case <-time.After(i.opt.retryInterval):
+			case <-i.done:
+				return
+			}
+			continue
+		}
+		switch msg := msg.(type) {
+		case *redis.Subscription:
+			if msg.Kind == "subscribe" {
+				i.local.Clear()
+			}
+		case *redis.Message:
+			i.handle(msg.Payload)
+		}
+	}
+}
+
+// handle 删除本地缓存中失效的 key（field）
+func (i *Invalidator) handle(payload string) {
+	var msg invalidation
+	if err := json.Unmarshal([]byte(payload), &msg); err != nil {
+		i.opt.errHandler(fmt.Errorf("caredis: unmarshal invalidation error:%w", err))
+		return
+	}
+	ctx := context.Background()
+	var err error
+	switch {
+	case len(msg.Keys) > 0:
+		err = i.local.MDel(ctx, msg.Keys...)
+	case msg.Hash != "" && len(msg.Fields) > 0:
+		err = i.local.HMDel(ctx, msg.Hash, msg.Fields...)
+	case msg.Hash != "":
+		err = i.local.HDel(ctx, msg.Hash)
+	}
+	if err != nil {
+		i.opt.errHandler(fmt.Errorf("caredis: local invalidation error:%w", err))
+	}
+}
+
+func (i *Invalidator) closed() bool {
+	select {
+	case <-i.done:
+		return true
+	default:
+		return false
+	}
+}
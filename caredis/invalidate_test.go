@@ -0,0 +1,74 @@
+package caredis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/erkesi/cacheaside/cache"
+	"github.com/erkesi/cacheaside/cache/memory"
+)
+
+func TestInvalidatorHandle(t *testing.T) {
+	ctx := context.Background()
+	local := memory.New()
+	_ = local.MSet(ctx, nil, &cache.KV{Key: "ns$1", Data: []byte("v1")}, &cache.KV{Key: "ns$2", Data: []byte("v2")})
+	_ = local.HMSet(ctx, "h", nil, &cache.KV{Key: "f1", Data: []byte("v1")}, &cache.KV{Key: "f2", Data: []byte("v2")})
+	var errs []error
+	i := &Invalidator{local: local, opt: &InvalidateOption{errHandler: func(err error) {
+		errs = append(errs, err)
+	}}}
+
+	i.handle(`{"keys":["ns$1"]}`)
+	i.handle(`{"hash":"h","fields":["f1"]}`)
+	i.handle(`invalid`)
+	res, _ := local.MGet(ctx, "ns$1", "ns$2")
+	if len(res) != 1 || res["ns$2"] == nil {
+		t.Fatalf("unexpected res %v", res)
+	}
+	if res, _ = local.HMGet(ctx, "h", "f1", "f2"); len(res) != 1 || res["f2"] == nil {
+		t.Fatalf("unexpected res %v", res)
+	}
+	if len(errs) != 1 {
+		t.Fatalf("unexpected errs %v", errs)
+	}
+	i.handle(`{"hash":"h"}`)
+	if local.Len() != 1 {
+		t.Fatalf("unexpected len %d", local.Len())
+	}
+}
+
+func TestInvalidator(t *testing.T) {
+	ctx := context.Background()
+	local1, local2 := memory.New(), memory.New()
+	inv1 := NewInvalidator(redisWarp.cli, "cacheaside:invalidate", local1)
+	defer inv1.Close()
+	inv2 := NewInvalidator(redisWarp.cli, "cacheaside:invalidate", local2)
+	defer inv2.Close()
+	// 等待订阅成功（订阅成功时清空本地缓存）
+	time.Sleep(100 * time.Millisecond)
+
+	c1 := cache.Chain(cache.NewTwoLevelCacher(local1, redisWarp, time.Minute), inv1.Middleware())
+	c2 := cache.Chain(cache.NewTwoLevelCacher(local2, redisWarp, time.Minute), inv2.Middleware())
+	ttl := time.Minute
+	if err := c1.MSet(ctx, &ttl, &cache.KV{Key: "inv1", Data: []byte("v1")}); err != nil {
+		t.Fatal(err)
+	}
+	if res, err := c2.MGet(ctx, "inv1"); err != nil || string(res["inv1"]) != "v1" {
+		t.Fatalf("unexpected res %v, err %v", res, err)
+	}
+	if err := c1.MDel(ctx, "inv1"); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		res, _ := local2.MGet(ctx, "inv1")
+		if len(res) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("local2 should be invalidated")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -9,12 +9,18 @@ import (
 )
 
 type RedisWrap struct {
-	cli *redis.Client
+	cli redis.UniversalClient
+	// cluster 为 redis cluster 时，多 key 命令按 slot 拆分
+	cluster bool
 }
 
-func NewRedisWrap(cli *redis.Client) *RedisWrap {
+// NewRedisWrap cli 可为 redis.Client（含 redis.NewFailoverClient 创建的 sentinel 客户端）、redis.ClusterClient、redis.Ring，
+// 或 redis.NewUniversalClient 创建的客户端；其他实现无法绑定 ctx，缓存读写不受 ctx 的超时与取消控制
+func NewRedisWrap(cli redis.UniversalClient) *RedisWrap {
+	_, cluster := cli.(*redis.ClusterClient)
 	return &RedisWrap{
-		cli: cli,
+		cli:     cli,
+		cluster: cluster,
 	}
 }
 
@@ -22,17 +28,21 @@ func (r *RedisWrap) MSet(ctx context.Context, ttl *time.Duration, kvs ...*cache.
 	if len(kvs) == 0 {
 		return nil
 	}
-	pipeline := r.cli.WithContext(ctx).Pipeline()
+	pipeline := withContext(ctx, r.cli).Pipeline()
 	defer func() {
 		_ = pipeline.Close()
 	}()
 	resList := make([]*redis.StatusCmd, len(kvs))
 	for i, kv := range kvs {
-        if ttl == nil {
-            resList[i] = pipeline.Set(kv.Key, kv.Data, -1)
-        }else{
-            resList[i] = pipeline.Set(kv.Key, kv.Data, *ttl)
-        }
+		kvTTL := ttl
+		if kv.TTL != nil {
+			kvTTL = kv.TTL
+		}
+		if kvTTL == nil {
+			resList[i] = pipeline.Set(kv.Key, kv.Data, -1)
+		} else {
+			resList[i] = pipeline.Set(kv.Key, kv.Data, *kvTTL)
+		}
 	}
 	_, err := pipeline.Exec()
 	if err != nil {
@@ -50,16 +60,27 @@ func (r *RedisWrap) MGet(ctx context.Context, keys ...string) (map[string][]byte
 	if len(keys) == 0 {
 		return nil, nil
 	}
-	vals, err := r.cli.WithContext(ctx).MGet(keys...).Result()
+	pipeline := withContext(ctx, r.cli).Pipeline()
+	defer func() {
+		_ = pipeline.Close()
+	}()
+	groups := r.groups(keys)
+	valsCmds := make([]*redis.SliceCmd, len(groups))
+	for i, group := range groups {
+		valsCmds[i] = pipeline.MGet(group...)
+	}
+	_, err := pipeline.Exec()
 	if err != nil {
 		return nil, err
 	}
 	key2Data := make(map[string][]byte)
-	for i, v := range vals {
-        if v == nil {
-			continue
+	for i, valsCmd := range valsCmds {
+		for j, v := range valsCmd.Val() {
+			if v == nil {
+				continue
+			}
+			key2Data[groups[i][j]] = []byte(v.(string))
 		}
-		key2Data[keys[i]] = []byte(v.(string))
 	}
 	return key2Data, nil
 }
@@ -68,14 +89,26 @@ func (r *RedisWrap) MDel(ctx context.Context, keys ...string) error {
 	if len(keys) == 0 {
 		return nil
 	}
-	return r.cli.WithContext(ctx).Del(keys...).Err()
+	groups := r.groups(keys)
+	if len(groups) == 1 {
+		return withContext(ctx, r.cli).Del(keys...).Err()
+	}
+	pipeline := withContext(ctx, r.cli).Pipeline()
+	defer func() {
+		_ = pipeline.Close()
+	}()
+	for _, group := range groups {
+		pipeline.Del(group...)
+	}
+	_, err := pipeline.Exec()
+	return err
 }
 
 func (r *RedisWrap) HMSet(ctx context.Context, key string, ttl *time.Duration, kvs ...*cache.KV) error {
 	if len(kvs) == 0 {
 		return nil
 	}
-	pipeline := r.cli.WithContext(ctx).Pipeline()
+	pipeline := withContext(ctx, r.cli).Pipeline()
 	defer func() {
 		_ = pipeline.Close()
 	}()
@@ -102,7 +135,7 @@ func (r *RedisWrap) HMGet(ctx context.Context, key string, fields ...string) (ma
 	if len(fields) == 0 {
 		return nil, nil
 	}
-	vals, err := r.cli.WithContext(ctx).HMGet(key, fields...).Result()
+	vals, err := withContext(ctx, r.cli).HMGet(key, fields...).Result()
 	if err != nil {
 		return nil, err
 	}
@@ -117,9 +150,164 @@ func (r *RedisWrap) HMGet(ctx context.Context, key string, fields ...string) (ma
 }
 
 func (r *RedisWrap) HDel(ctx context.Context, key string) error {
-	return r.cli.WithContext(ctx).Del(key).Err()
+	return withContext(ctx, r.cli).Del(key).Err()
 }
 
 func (r *RedisWrap) HMDel(ctx context.Context, key string, fields ...string) error {
-	return r.cli.WithContext(ctx).HDel(key, fields...).Err()
+	return withContext(ctx, r.cli).HDel(key, fields...).Err()
+}
+
+func (r *RedisWrap) MGetTTL(ctx context.Context, keys ...string) (map[string][]byte, map[string]time.Duration, error) {
+	if len(keys) == 0 {
+		return nil, nil, nil
+	}
+	pipeline := withContext(ctx, r.cli).Pipeline()
+	defer func() {
+		_ = pipeline.Close()
+	}()
+	groups := r.groups(keys)
+	valsCmds := make([]*redis.SliceCmd, len(groups))
+	ttlCmds := make([][]*redis.DurationCmd, len(groups))
+	for i, group := range groups {
+		valsCmds[i] = pipeline.MGet(group...)
+		for _, key := range group {
+			ttlCmds[i] = append(ttlCmds[i], pipeline.PTTL(key))
+		}
+	}
+	_, err := pipeline.Exec()
+	if err != nil {
+		return nil, nil, err
+	}
+	key2Data := make(map[string][]byte)
+	ttls := make(map[string]time.Duration)
+	for i, valsCmd := range valsCmds {
+		for j, v := range valsCmd.Val() {
+			if v == nil {
+				continue
+			}
+			key := groups[i][j]
+			key2Data[key] = []byte(v.(string))
+			if ttl := ttlCmds[i][j].Val(); ttl > 0 {
+				ttls[key] = ttl
+			}
+		}
+	}
+	return key2Data, ttls, nil
+}
+
+func (r *RedisWrap) HMGetTTL(ctx context.Context, key string, fields ...string) (map[string][]byte, *time.Duration, error) {
+	if len(fields) == 0 {
+		return nil, nil, nil
+	}
+	pipeline := withContext(ctx, r.cli).Pipeline()
+	defer func() {
+		_ = pipeline.Close()
+	}()
+	valsCmd := pipeline.HMGet(key, fields...)
+	ttlCmd := pipeline.PTTL(key)
+	_, err := pipeline.Exec()
+	if err != nil {
+		return nil, nil, err
+	}
+	key2Data := make(map[string][]byte)
+	for i, v := range valsCmd.Val() {
+		if v == nil {
+			continue
+		}
+		key2Data[fields[i]] = []byte(v.(string))
+	}
+	var ttl *time.Duration
+	if d := ttlCmd.Val(); d > 0 {
+		ttl = &d
+	}
+	return key2Data, ttl, nil
+}
+
+func (r *RedisWrap) Load(ctx context.Context, key string) (int64, error) {
+	val, err := withContext(ctx, r.cli).Get(key).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return val, err
+}
+
+func (r *RedisWrap) Incr(ctx context.Context, key string) (int64, error) {
+	return withContext(ctx, r.cli).Incr(key).Result()
+}
+
+// unlockScript 仅当锁仍由 token 持有时删除
+var unlockScript = redis.NewScript(`if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("del", KEYS[1])
+else
+	return 0
+end`)
+
+func (r *RedisWrap) MLock(ctx context.Context, token string, ttl time.Duration, keys ...string) ([]string, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	pipeline := withContext(ctx, r.cli).Pipeline()
+	defer func() {
+		_ = pipeline.Close()
+	}()
+	resList := make([]*redis.BoolCmd, len(keys))
+	for i, key := range keys {
+		resList[i] = pipeline.SetNX(key, token, ttl)
+	}
+	_, err := pipeline.Exec()
+	if err != nil {
+		return nil, err
+	}
+	var locked []string
+	for i, res := range resList {
+		if res.Val() {
+			locked = append(locked, keys[i])
+		}
+	}
+	return locked, nil
+}
+
+func (r *RedisWrap) MUnlock(ctx context.Context, token string, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	pipeline := withContext(ctx, r.cli).Pipeline()
+	defer func() {
+		_ = pipeline.Close()
+	}()
+	resList := make([]*redis.Cmd, len(keys))
+	for i, key := range keys {
+		resList[i] = unlockScript.Eval(pipeline, []string{key}, token)
+	}
+	_, err := pipeline.Exec()
+	if err != nil {
+		return err
+	}
+	for _, res := range resList {
+		if res.Err() != nil {
+			return res.Err()
+		}
+	}
+	return nil
+}
+
+// groups cluster 模式下按 slot 拆分 keys，否则不拆分
+func (r *RedisWrap) groups(keys []string) [][]string {
+	if !r.cluster {
+		return [][]string{keys}
+	}
+	return groupBySlot(keys)
+}
+
+// withContext 返回绑定 ctx 的客户端，无法绑定 ctx 的实现返回 cli 本身
+func withContext(ctx context.Context, cli redis.UniversalClient) redis.Cmdable {
+	switch cli := cli.(type) {
+	case *redis.Client:
+		return cli.WithContext(ctx)
+	case *redis.ClusterClient:
+		return cli.WithContext(ctx)
+	case *redis.Ring:
+		return cli.WithContext(ctx)
+	}
+	return cli
 }
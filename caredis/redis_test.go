@@ -50,6 +50,7 @@ func TestHCache(t *testing.T) {
 		})
 	}
 	ctx := context.Background()
+	ttl := time.Hour
 	err := redisWarp.HMDel(ctx, key, "Name", "Age")
 	if err != nil {
 		t.Fatal(err)
@@ -58,7 +59,7 @@ func TestHCache(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-    err = redisWarp.HMSet(ctx, key, time.Hour, kvs...)
+    err = redisWarp.HMSet(ctx, key, &ttl, kvs...)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -119,11 +120,12 @@ func TestCache(t *testing.T) {
 	}
 
 	ctx := context.Background()
+	ttl := time.Hour
 	err := redisWarp.MDel(ctx, "0", "1", "nil")
 	if err != nil {
 		t.Fatal(err)
 	}
-	err = redisWarp.MSet(ctx, time.Hour, kvs...)
+	err = redisWarp.MSet(ctx, &ttl, kvs...)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -141,8 +143,123 @@ func TestCache(t *testing.T) {
     if err != nil {
         t.Fatal(err)
     }
-    err = redisWarp.MSet(ctx, time.Hour, kvs...)
+    err = redisWarp.MSet(ctx, &ttl, kvs...)
     if err != nil {
         t.Fatal(err)
     }
 }
+
+func TestCacheKVTTL(t *testing.T) {
+	ctx := context.Background()
+	ttl := time.Hour
+	kvTTL := time.Minute
+	err := redisWarp.MSet(ctx, &ttl,
+		&cache.KV{Key: "ttl0", Data: []byte("0")},
+		&cache.KV{Key: "ttl1", Data: []byte("1"), TTL: &kvTTL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d0, err := redisWarp.cli.TTL("ttl0").Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d1, err := redisWarp.cli.TTL("ttl1").Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d0 <= kvTTL || d1 > kvTTL {
+		t.Fatalf("unexpected ttl %v %v", d0, d1)
+	}
+	err = redisWarp.MDel(ctx, "ttl0", "ttl1")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCounter(t *testing.T) {
+	ctx := context.Background()
+	err := redisWarp.MDel(ctx, "counter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	val, err := redisWarp.Load(ctx, "counter")
+	if err != nil || val != 0 {
+		t.Fatalf("unexpected val %d, err %v", val, err)
+	}
+	val, err = redisWarp.Incr(ctx, "counter")
+	if err != nil || val != 1 {
+		t.Fatalf("unexpected val %d, err %v", val, err)
+	}
+	val, err = redisWarp.Load(ctx, "counter")
+	if err != nil || val != 1 {
+		t.Fatalf("unexpected val %d, err %v", val, err)
+	}
+	err = redisWarp.MDel(ctx, "counter")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLocker(t *testing.T) {
+	ctx := context.Background()
+	err := redisWarp.MDel(ctx, "lock0", "lock1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	locked, err := redisWarp.MLock(ctx, "a", time.Minute, "lock0")
+	if err != nil || !reflect.DeepEqual(locked, []string{"lock0"}) {
+		t.Fatalf("unexpected locked %v, err %v", locked, err)
+	}
+	locked, err = redisWarp.MLock(ctx, "b", time.Minute, "lock0", "lock1")
+	if err != nil || !reflect.DeepEqual(locked, []string{"lock1"}) {
+		t.Fatalf("unexpected locked %v, err %v", locked, err)
+	}
+	// 非持有者释放无效
+	err = redisWarp.MUnlock(ctx, "b", "lock0", "lock1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	locked, err = redisWarp.MLock(ctx, "c", time.Minute, "lock0", "lock1")
+	if err != nil || !reflect.DeepEqual(locked, []string{"lock1"}) {
+		t.Fatalf("unexpected locked %v, err %v", locked, err)
+	}
+	err = redisWarp.MUnlock(ctx, "a", "lock0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = redisWarp.MDel(ctx, "lock1")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	ctx := context.Background()
+	ttl := time.Minute
+	if err := redisWarp.MSet(ctx, &ttl, &cache.KV{Key: "ttl1", Data: []byte("v1")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := redisWarp.MSet(ctx, nil, &cache.KV{Key: "ttl2", Data: []byte("v2")}); err != nil {
+		t.Fatal(err)
+	}
+	defer redisWarp.MDel(ctx, "ttl1", "ttl2")
+	key2Data, ttls, err := redisWarp.MGetTTL(ctx, "ttl1", "ttl2", "ttl3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(key2Data) != 2 || len(ttls) != 1 || ttls["ttl1"] <= 0 || ttls["ttl1"] > ttl {
+		t.Fatalf("unexpected result %v %v", key2Data, ttls)
+	}
+
+	if err = redisWarp.HMSet(ctx, "httl", &ttl, &cache.KV{Key: "f1", Data: []byte("v1")}); err != nil {
+		t.Fatal(err)
+	}
+	defer redisWarp.HDel(ctx, "httl")
+	field2Data, hTTL, err := redisWarp.HMGetTTL(ctx, "httl", "f1", "f2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(field2Data) != 1 || hTTL == nil || *hTTL > ttl {
+		t.Fatalf("unexpected result %v %v", field2Data, hTTL)
+	}
+}
@@ -0,0 +1,56 @@
+package caredis
+
+import (
+	"strings"
+)
+
+// slotNumber redis cluster 的 slot 数
+const slotNumber = 16384
+
+// hashTag 返回 key 中用于计算 slot 的部分：第一个 { 与其后第一个 } 之间非空时为 hash tag，否则为整个 key
+func hashTag(key string) string {
+	if s := strings.IndexByte(key, '{'); s > -1 {
+		if e := strings.IndexByte(key[s+1:], '}'); e > 0 {
+			return key[s+1 : s+e+1]
+		}
+	}
+	return key
+}
+
+// slot 返回 key 所在的 slot
+func slot(key string) int {
+	return int(crc16(hashTag(key)) % slotNumber)
+}
+
+// crc16 CRC16-CCITT（XMODEM），redis cluster 的 key 哈希算法
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// groupBySlot 按 slot 分组 keys，组内保持 keys 原有顺序
+func groupBySlot(keys []string) [][]string {
+	index := make(map[int]int)
+	var groups [][]string
+	for _, key := range keys {
+		s := slot(key)
+		i, ok := index[s]
+		if !ok {
+			i = len(groups)
+			index[s] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], key)
+	}
+	return groups
+}
@@ -0,0 +1,68 @@
+package caredis
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/go-redis/redis"
+)
+
+func TestSlot(t *testing.T) {
+	if s := slot("123456789"); s != 12739 {
+		t.Fatalf("unexpected slot %d", s)
+	}
+	if slot("{user1000}.following") != slot("{user1000}.followers") {
+		t.Fatal("keys with the same hash tag should be in the same slot")
+	}
+	for key, tag := range map[string]string{
+		"ns${g1}1":      "g1",
+		"foo{}{bar}":    "foo{}{bar}",
+		"foo{{bar}}zap": "{bar",
+		"foo{bar}{zap}": "bar",
+		"ns$1":          "ns$1",
+	} {
+		if got := hashTag(key); got != tag {
+			t.Fatalf("unexpected hash tag of %s: %s", key, got)
+		}
+	}
+	groups := groupBySlot([]string{"a{1}", "b", "c{1}"})
+	if !reflect.DeepEqual(groups, [][]string{{"a{1}", "c{1}"}, {"b"}}) {
+		t.Fatalf("unexpected groups %v", groups)
+	}
+}
+
+func TestRedisWrapGroups(t *testing.T) {
+	keys := []string{"ns${g1}1", "ns${g1}2", "ns$3"}
+	cli := redis.NewClient(&redis.Options{Addr: "127.0.0.1:6379"})
+	defer cli.Close()
+	if groups := NewRedisWrap(cli).groups(keys); len(groups) != 1 {
+		t.Fatalf("unexpected groups %v", groups)
+	}
+	clusterCli := redis.NewClusterClient(&redis.ClusterOptions{Addrs: []string{"127.0.0.1:7000"}})
+	defer clusterCli.Close()
+	groups := NewRedisWrap(clusterCli).groups(keys)
+	if !reflect.DeepEqual(groups, [][]string{{"ns${g1}1", "ns${g1}2"}, {"ns$3"}}) {
+		t.Fatalf("unexpected groups %v", groups)
+	}
+}
+
+type universalClient struct {
+	redis.UniversalClient
+}
+
+func TestWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ring := redis.NewRing(&redis.RingOptions{Addrs: map[string]string{"a": "127.0.0.1:6379"}})
+	defer ring.Close()
+	if c, ok := withContext(ctx, ring).(*redis.Ring); !ok || c.Context() != ctx {
+		t.Fatal("expected ring bound to ctx")
+	}
+	// 无法绑定 ctx 的实现不 panic，直接使用 cli
+	cli := universalClient{UniversalClient: ring}
+	if c := withContext(ctx, cli); c != redis.Cmdable(cli) {
+		t.Fatalf("unexpected client %T", c)
+	}
+	NewRedisWrap(cli)
+}
@@ -0,0 +1,114 @@
+package cacheaside
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	defaultDelayedDelAttempts = 3
+	defaultDelayedDelBackoff  = 100 * time.Millisecond
+)
+
+// delayer 延迟任务调度器，关闭时立即执行尚未到期的任务
+type delayer struct {
+	mu     sync.Mutex
+	tasks  map[*delayTask]struct{}
+	wg     sync.WaitGroup
+	closed bool
+}
+
+type delayTask struct {
+	timer *time.Timer
+	ctx   context.Context
+	run   func(ctx context.Context)
+}
+
+func newDelayer() *delayer {
+	return &delayer{
+		tasks: make(map[*delayTask]struct{}),
+	}
+}
+
+// schedule 延迟 delay 后执行 run，已关闭时返回 false
+func (d *delayer) schedule(ctx context.Context, delay time.Duration, run func(ctx context.Context)) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return false
+	}
+	t := &delayTask{ctx: ctx, run: run}
+	d.tasks[t] = struct{}{}
+	d.wg.Add(1)
+	t.timer = time.AfterFunc(delay, func() {
+		defer d.wg.Done()
+		if d.take(t) {
+			t.run(t.ctx)
+		}
+	})
+	return true
+}
+
+func (d *delayer) take(t *delayTask) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.tasks[t]; !ok {
+		return false
+	}
+	delete(d.tasks, t)
+	return true
+}
+
+// close 停止接收新任务，立即执行尚未到期的任务（使用 ctx 控制超时），并等待所有任务完成
+func (d *delayer) close(ctx context.Context) error {
+	d.mu.Lock()
+	d.closed = true
+	var pending []*delayTask
+	for t := range d.tasks {
+		// Stop 失败说明任务已到期，由定时器协程执行
+		if t.timer.Stop() {
+			delete(d.tasks, t)
+			pending = append(pending, t)
+			d.wg.Done()
+		}
+	}
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		for _, t := range pending {
+			t.run(ctx)
+		}
+		d.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("cacheaside: delayer close error:%w", ctx.Err())
+	}
+}
+
+// retry 执行 fn，失败时按指数退避重试，直至成功、达到次数上限或 ctx 结束；attempts 小于 1 时执行一次
+func retry(ctx context.Context, attempts int, backoff time.Duration, fn func(ctx context.Context) error) error {
+	if attempts < 1 {
+		attempts = 1
+	}
+	var err error
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			select {
+			case <-time.After(backoff << (i - 1)):
+			case <-ctx.Done():
+				return fmt.Errorf("%w, last error:%v", ctx.Err(), err)
+			}
+		}
+		if err = fn(ctx); err == nil {
+			return nil
+		}
+	}
+	return err
+}
@@ -0,0 +1,88 @@
+package cacheaside
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/erkesi/cacheaside/cache"
+	"github.com/erkesi/cacheaside/code"
+	"github.com/golang/mock/gomock"
+)
+
+func TestMDelDelayed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var dels int32
+	done := make(chan struct{})
+	errs := make(chan error, 1)
+	mcache := cache.NewMockCacher(ctrl)
+	mcache.EXPECT().MDel(gomock.Any(), "ns$1", "ns$2").DoAndReturn(func(ctx context.Context, keys ...string) error {
+		// 第二次删除首次失败，重试成功
+		switch atomic.AddInt32(&dels, 1) {
+		case 2:
+			return errors.New("mock error")
+		case 3:
+			close(done)
+		}
+		return nil
+	}).Times(3)
+
+	ca := NewCacheAside(&code.Json{}, mcache, "ns")
+	caf := ca.Fetch(func(ctx context.Context, keys []string, extra ...interface{}) ([]interface{}, error) {
+		return nil, nil
+	}, func(ctx context.Context, v interface{}, extra ...interface{}) (string, error) {
+		return "", nil
+	}, WithDelayedDelRetry(2, time.Millisecond), WithDelayedDelErrHandler(
+		func(ctx context.Context, err error, keys, fields []string) {
+			errs <- err
+		}))
+
+	err := caf.MDelDelayed(context.Background(), 10*time.Millisecond, "1", "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt32(&dels) != 1 {
+		t.Fatal("expected immediate delete")
+	}
+	select {
+	case <-done:
+	case err = <-errs:
+		t.Fatalf("unexpected error %v", err)
+	case <-time.After(time.Second):
+		t.Fatalf("expected delayed delete with retry, got %d", atomic.LoadInt32(&dels))
+	}
+}
+
+func TestRetryAtLeastOnce(t *testing.T) {
+	var calls int
+	err := retry(context.Background(), 0, time.Millisecond, func(ctx context.Context) error {
+		calls++
+		return errors.New("mock error")
+	})
+	if err == nil || calls != 1 {
+		t.Fatalf("unexpected err %v, calls %d", err, calls)
+	}
+}
+
+func TestDelayerCloseFlush(t *testing.T) {
+	d := newDelayer()
+	var runs int32
+	for i := 0; i < 3; i++ {
+		d.schedule(context.Background(), time.Hour, func(ctx context.Context) {
+			atomic.AddInt32(&runs, 1)
+		})
+	}
+	if err := d.close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt32(&runs) != 3 {
+		t.Fatalf("expected pending tasks flushed, got %d", runs)
+	}
+	if d.schedule(context.Background(), time.Millisecond, func(ctx context.Context) {}) {
+		t.Fatal("closed delayer should reject tasks")
+	}
+}
@@ -0,0 +1,75 @@
+package cacheaside
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// envelopeMagic 缓存值封装的头部标识，0xc1 在 msgpack 中未被使用，json 也不会以此开头
+var envelopeMagic = []byte{0xc1, 'C', 'A'}
+
+// 标记 envelope 中携带的字段，前若干位与 envelope.fields 一一对应
+const (
+	// flagSoftExpire 携带软过期时间
+	flagSoftExpire byte = 1 << iota
+	// flagExpire 携带过期时间
+	flagExpire
+	// flagDelta 携带回源耗时
+	flagDelta
+	// flagNil 空值，即回源确认不存在，不携带数据
+	flagNil
+)
+
+// envelope 缓存值封装：magic + flags + 按 flags 顺序排列的 int64 字段 + Coder 编码后的数据
+type envelope struct {
+	flags      byte
+	softExpire int64 // unix nano
+	expire     int64 // unix nano
+	delta      int64 // nanosecond
+	data       []byte
+}
+
+func (e *envelope) fields() []*int64 {
+	return []*int64{&e.softExpire, &e.expire, &e.delta}
+}
+
+func (e *envelope) encode() []byte {
+	fields := e.fields()
+	buf := make([]byte, 0, len(envelopeMagic)+1+8*len(fields)+len(e.data))
+	buf = append(buf, envelopeMagic...)
+	buf = append(buf, e.flags)
+	for i, field := range fields {
+		if e.flags&(1<<i) != 0 {
+			buf = appendInt64(buf, *field)
+		}
+	}
+	return append(buf, e.data...)
+}
+
+// decodeEnvelope 解析缓存值，非封装格式（未开启相关选项时写入的数据）返回 false
+func decodeEnvelope(bs []byte) (*envelope, bool) {
+	if !bytes.HasPrefix(bs, envelopeMagic) || len(bs) < len(envelopeMagic)+1 {
+		return nil, false
+	}
+	bs = bs[len(envelopeMagic):]
+	e := &envelope{flags: bs[0]}
+	bs = bs[1:]
+	for i, field := range e.fields() {
+		if e.flags&(1<<i) == 0 {
+			continue
+		}
+		if len(bs) < 8 {
+			return nil, false
+		}
+		*field = int64(binary.BigEndian.Uint64(bs))
+		bs = bs[8:]
+	}
+	e.data = bs
+	return e, true
+}
+
+func appendInt64(buf []byte, v int64) []byte {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(v))
+	return append(buf, b[:]...)
+}
@@ -0,0 +1,104 @@
+package cacheaside
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// flightGroup 按 key 合并回源：已在回源中的 key 等待其结果，其余 key 合并为一批回源
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[flightKey]*flightCall
+}
+
+type flightKey struct {
+	group string
+	key   string
+}
+
+type flightCall struct {
+	done chan struct{}
+	// val 回源结果，nil 表示数据不存在
+	val interface{}
+	err error
+}
+
+// do 回源 group 下的 keys，fn 只接收未在回源中的 keys，返回 key 到值的映射与失败 key 的错误；
+// fn 在独立协程中执行，调用方（包括发起回源的调用方）在自身 ctx 结束时停止等待，不影响其他等待方；
+// 返回全部 keys 的回源结果，不存在的 key 不在结果中，回源失败的 key 记录在失败 key 的错误中
+func (g *flightGroup) do(ctx context.Context, group string, keys []string,
+	fn loadFunc) (map[string]interface{}, map[string]error, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[flightKey]*flightCall)
+	}
+	var ownKeys []string
+	own := make(map[string]*flightCall)
+	calls := make(map[string]*flightCall, len(keys))
+	for _, key := range keys {
+		fk := flightKey{group: group, key: key}
+		if c, ok := g.calls[fk]; ok {
+			calls[key] = c
+			continue
+		}
+		c := &flightCall{done: make(chan struct{})}
+		g.calls[fk] = c
+		own[key] = c
+		calls[key] = c
+		ownKeys = append(ownKeys, key)
+	}
+	g.mu.Unlock()
+
+	if len(ownKeys) > 0 {
+		go g.call(group, own, ownKeys, fn)
+	}
+	res := make(map[string]interface{}, len(keys))
+	errs := make(map[string]error)
+	for key, c := range calls {
+		select {
+		case <-c.done:
+		case <-ctx.Done():
+			return nil, nil, fmt.Errorf("cacheaside: wait fetchSource error:%w", ctx.Err())
+		}
+		if c.err != nil {
+			errs[key] = c.err
+			continue
+		}
+		if c.val != nil {
+			res[key] = c.val
+		}
+	}
+	return res, errs, nil
+}
+
+// call 执行 fn 并将结果分发给等待 own 的调用方，fn panic 时同样释放等待方
+func (g *flightGroup) call(group string, own map[string]*flightCall, keys []string, fn loadFunc) {
+	var (
+		vals map[string]interface{}
+		errs map[string]error
+		err  error
+	)
+	normal := false
+	defer func() {
+		if !normal {
+			err = errors.New("cacheaside: fetchSource panic")
+		}
+		g.mu.Lock()
+		for key, c := range own {
+			c.val, c.err = vals[key], err
+			if c.err == nil {
+				c.err = errs[key]
+			}
+			delete(g.calls, flightKey{group: group, key: key})
+			close(c.done)
+		}
+		g.mu.Unlock()
+	}()
+	vals, errs, err = fn(keys)
+	normal = true
+}
+
+// loadFunc 回源查询 keys，返回 key 到值的映射与失败 key 的错误
+type loadFunc func(keys []string) (map[string]interface{}, map[string]error, error)
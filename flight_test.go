@@ -0,0 +1,129 @@
+package cacheaside
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFlightGroup(t *testing.T) {
+	var g flightGroup
+	started := make(chan struct{})
+	block := make(chan struct{})
+	var mu sync.Mutex
+	var batches [][]string
+	fn := func(keys []string) (map[string]interface{}, map[string]error, error) {
+		mu.Lock()
+		batches = append(batches, keys)
+		mu.Unlock()
+		if keys[0] == "a" {
+			close(started)
+			<-block
+		}
+		m := make(map[string]interface{})
+		for _, key := range keys {
+			if key != "c" {
+				m[key] = key + "1"
+			}
+		}
+		return m, nil, nil
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		res, _, err := g.do(context.Background(), "", []string{"a", "b"}, fn)
+		if err != nil || len(res) != 2 || res["b"] != "b1" {
+			t.Errorf("unexpected res %v, err %v", res, err)
+		}
+	}()
+	<-started
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		res, _, err := g.do(context.Background(), "", []string{"b", "c", "d"}, fn)
+		if err != nil || len(res) != 2 || res["b"] != "b1" || res["d"] != "d1" {
+			t.Errorf("unexpected res %v, err %v", res, err)
+		}
+	}()
+	// 等待第二批中未在回源的 key 完成回源，b 仍在等待第一批
+	for {
+		mu.Lock()
+		n := len(batches)
+		mu.Unlock()
+		if n == 2 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(block)
+	wg.Wait()
+	<-done
+	if !reflect.DeepEqual(batches, [][]string{{"a", "b"}, {"c", "d"}}) {
+		t.Fatalf("unexpected batches %v", batches)
+	}
+}
+
+func TestFlightGroupCancel(t *testing.T) {
+	var g flightGroup
+	block := make(chan struct{})
+	fn := func(keys []string) (map[string]interface{}, map[string]error, error) {
+		<-block
+		return map[string]interface{}{"a": "a1"}, nil, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	leaderDone := make(chan error)
+	go func() {
+		_, _, err := g.do(ctx, "", []string{"a"}, fn)
+		leaderDone <- err
+	}()
+	// 等待 leader 发起回源
+	for {
+		g.mu.Lock()
+		n := len(g.calls)
+		g.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	followerDone := make(chan map[string]interface{})
+	go func() {
+		res, _, err := g.do(context.Background(), "", []string{"a"}, fn)
+		if err != nil {
+			t.Errorf("unexpected err %v", err)
+		}
+		followerDone <- res
+	}()
+
+	cancel()
+	if err := <-leaderDone; !errors.Is(err, context.Canceled) {
+		t.Fatalf("unexpected err %v", err)
+	}
+	close(block)
+	if res := <-followerDone; res["a"] != "a1" {
+		t.Fatalf("unexpected res %v", res)
+	}
+}
+
+func TestSourceContext(t *testing.T) {
+	type ctxKey struct{}
+	opt := newOption()
+	WithSourceTimeout(time.Minute)(opt)
+	parent, cancel := context.WithCancel(context.WithValue(context.Background(), ctxKey{}, "v"))
+	ctx, sourceCancel := opt.sourceContext(parent)
+	defer sourceCancel()
+	cancel()
+	if ctx.Err() != nil || ctx.Value(ctxKey{}) != "v" {
+		t.Fatalf("unexpected ctx err %v value %v", ctx.Err(), ctx.Value(ctxKey{}))
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		t.Fatal("source ctx should have deadline")
+	}
+}
@@ -0,0 +1,94 @@
+package cacheaside
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/erkesi/cacheaside/cache"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// genKeyFormat namespace 版本号的存储 key
+	genKeyFormat = "%s:gen"
+	// genNamespaceFormat 开启版本号后生效的 namespace
+	genNamespaceFormat = "%s:%d"
+	// genLoadTimeout 读取版本号的超时时间，读取不随调用方取消
+	genLoadTimeout = 3 * time.Second
+)
+
+// generation namespace 版本号，版本号变更后旧版本的缓存不再被访问，等待自然过期；本地缓存 ttl 时间
+type generation struct {
+	counter  cache.Counter
+	key      string
+	ttl      time.Duration
+	sfg      singleflight.Group
+	mu       sync.Mutex
+	loaded   bool
+	val      int64
+	expireAt time.Time
+}
+
+func newGeneration(counter cache.Counter, namespace string, ttl time.Duration) *generation {
+	return &generation{
+		counter: counter,
+		key:     fmt.Sprintf(genKeyFormat, namespace),
+		ttl:     ttl,
+	}
+}
+
+func (g *generation) get(ctx context.Context) (int64, error) {
+	g.mu.Lock()
+	if g.loaded && time.Now().Before(g.expireAt) {
+		val := g.val
+		g.mu.Unlock()
+		return val, nil
+	}
+	g.mu.Unlock()
+	// 合并的读取使用独立的 ctx，避免首个调用方取消导致其他等待方失败
+	ch := g.sfg.DoChan(g.key, func() (interface{}, error) {
+		ctx, cancel := context.WithTimeout(detachContext(ctx), genLoadTimeout)
+		defer cancel()
+		val, err := g.counter.Load(ctx, g.key)
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		if err != nil {
+			// 读取失败时沿用旧版本号
+			if g.loaded {
+				g.expireAt = time.Now().Add(g.ttl)
+				return g.val, nil
+			}
+			return nil, err
+		}
+		g.store(val)
+		return val, nil
+	})
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			return 0, res.Err
+		}
+		return res.Val.(int64), nil
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+func (g *generation) incr(ctx context.Context) (int64, error) {
+	val, err := g.counter.Incr(ctx, g.key)
+	if err != nil {
+		return 0, err
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.store(val)
+	return val, nil
+}
+
+func (g *generation) store(val int64) {
+	g.loaded = true
+	g.val = val
+	g.expireAt = time.Now().Add(g.ttl)
+}
@@ -1,10 +1,18 @@
 module github.com/erkesi/cacheaside
 
-go 1.16
+go 1.18
 
 require (
-	github.com/erkesi/cacheaside/cache v1.0.1
+	github.com/erkesi/cacheaside/cache v1.1.0
 	github.com/erkesi/cacheaside/code v1.0.1
 	github.com/golang/mock v1.6.0
 	golang.org/x/sync v0.1.0
 )
+
+require (
+	github.com/vmihailenco/msgpack/v5 v5.3.5 // indirect
+	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
+)
+
+// 仓库内开发使用本地 cache 模块，依赖方使用 require 中已发布的版本（tag cache/v1.1.0）
+replace github.com/erkesi/cacheaside/cache => ./cache
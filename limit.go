@@ -0,0 +1,145 @@
+package cacheaside
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrSourceLimited 回源达到限流或并发上限
+var ErrSourceLimited = errors.New("cacheaside: source limited")
+
+// LimitPolicy 回源达到限流或并发上限时的处理策略
+type LimitPolicy int
+
+const (
+	// LimitPolicyWait 等待直至获得回源许可或 ctx 结束（默认）
+	LimitPolicyWait LimitPolicy = iota
+	// LimitPolicyFailFast 立即返回 ErrSourceLimited
+	LimitPolicyFailFast
+	// LimitPolicyServeStale 立即返回缓存中已过期的旧数据（StatusStale），无旧数据的 key 返回 ErrSourceLimited；
+	// 缓存中记录逻辑过期时间，实际过期时间延长 WithStaleWindow
+	LimitPolicyServeStale
+)
+
+// defaultStaleWindow LimitPolicyServeStale 时过期数据在缓存中保留的默认时间
+const defaultStaleWindow = 5 * time.Minute
+
+// limiter 回源限流（令牌桶）与并发控制（信号量），nil 表示不限制
+type limiter struct {
+	bucket *tokenBucket
+	sem    chan struct{}
+	wait   bool
+}
+
+func newLimiter(rate float64, burst, concurrency int, policy LimitPolicy) *limiter {
+	if rate <= 0 && concurrency <= 0 {
+		return nil
+	}
+	l := &limiter{wait: policy == LimitPolicyWait}
+	if rate > 0 {
+		l.bucket = newTokenBucket(rate, burst)
+	}
+	if concurrency > 0 {
+		l.sem = make(chan struct{}, concurrency)
+	}
+	return l
+}
+
+// acquire 获取回源许可，返回释放并发许可的函数；先获取并发许可再获取令牌，被拒绝时不消耗令牌
+func (l *limiter) acquire(ctx context.Context) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	release, err := l.acquireSem(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if l.bucket != nil {
+		if err = l.bucket.take(ctx, l.wait); err != nil {
+			release()
+			return nil, err
+		}
+	}
+	return release, nil
+}
+
+func (l *limiter) acquireSem(ctx context.Context) (func(), error) {
+	if l.sem == nil {
+		return func() {}, nil
+	}
+	release := func() { <-l.sem }
+	select {
+	case l.sem <- struct{}{}:
+		return release, nil
+	default:
+	}
+	if !l.wait {
+		return nil, ErrSourceLimited
+	}
+	select {
+	case l.sem <- struct{}{}:
+		return release, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("cacheaside: wait source limit error:%w", ctx.Err())
+	}
+}
+
+// tokenBucket 令牌桶，每秒生成 rate 个令牌，最多积累 burst 个
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if burst <= 0 {
+		burst = 1
+	}
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// take 取一个令牌，令牌不足时 wait 为 true 则预占令牌并等待，否则返回 ErrSourceLimited
+func (b *tokenBucket) take(ctx context.Context, wait bool) error {
+	b.mu.Lock()
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		b.mu.Unlock()
+		return nil
+	}
+	if !wait {
+		b.mu.Unlock()
+		return ErrSourceLimited
+	}
+	delay := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	b.tokens--
+	b.mu.Unlock()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// 归还预占的令牌
+		b.mu.Lock()
+		b.tokens++
+		b.mu.Unlock()
+		return fmt.Errorf("cacheaside: wait source limit error:%w", ctx.Err())
+	}
+}
@@ -0,0 +1,103 @@
+package cacheaside
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/erkesi/cacheaside/cache/memory"
+	"github.com/erkesi/cacheaside/code"
+)
+
+func TestLimiter(t *testing.T) {
+	ctx := context.Background()
+	l := newLimiter(1, 1, 0, LimitPolicyFailFast)
+	if _, err := l.acquire(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.acquire(ctx); !errors.Is(err, ErrSourceLimited) {
+		t.Fatalf("unexpected err %v", err)
+	}
+
+	l = newLimiter(0, 0, 1, LimitPolicyWait)
+	release, err := l.acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err = l.acquire(timeoutCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("unexpected err %v", err)
+	}
+	release()
+	if _, err = l.acquire(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	l = newLimiter(100, 1, 0, LimitPolicyWait)
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		if _, err = l.acquire(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if time.Since(start) < 5*time.Millisecond {
+		t.Fatal("second acquire should wait for a token")
+	}
+
+	// 并发许可被拒绝时不消耗令牌
+	l = newLimiter(0.001, 1, 1, LimitPolicyFailFast)
+	l.sem <- struct{}{}
+	if _, err = l.acquire(ctx); !errors.Is(err, ErrSourceLimited) {
+		t.Fatalf("unexpected err %v", err)
+	}
+	<-l.sem
+	if _, err = l.acquire(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSourceLimitServeStale(t *testing.T) {
+	type User struct {
+		Id   string
+		Name string
+	}
+
+	now := time.Now()
+	clock := func() time.Time {
+		return now
+	}
+	ca := NewCacheAside(&code.Json{}, memory.New(memory.WithClock(clock)), "ns")
+	caf := ca.Fetch(func(ctx context.Context, keys []string, extra ...interface{}) ([]interface{}, error) {
+		return []interface{}{&User{Id: "1", Name: "name1"}}, nil
+	}, func(ctx context.Context, v interface{}, extra ...interface{}) (string, error) {
+		return v.(*User).Id, nil
+	}, WithTTL(time.Minute), WithClock(clock), WithSourceRateLimit(0.001, 1),
+		WithSourceLimitPolicy(LimitPolicyServeStale), WithStaleWindow(time.Minute))
+
+	var u User
+	if _, err := caf.Get(context.Background(), "1", &u); err != nil {
+		t.Fatal(err)
+	}
+
+	// 逻辑过期后回源被限流，返回缓存中保留的旧数据
+	now = now.Add(90 * time.Second)
+	var us []*User
+	results, err := caf.MGetResults(context.Background(), []string{"1", "3"}, &us)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].Status != StatusStale || us[0].Name != "name1" {
+		t.Fatalf("unexpected result %v", results[0])
+	}
+	if results[1].Status != StatusMiss || !errors.Is(results[1].Err, ErrSourceLimited) {
+		t.Fatalf("unexpected result %v", results[1])
+	}
+
+	// 超过 staleWindow 后缓存中已无旧数据
+	now = now.Add(time.Minute)
+	if _, err = caf.Get(context.Background(), "1", &u); !errors.Is(err, ErrSourceLimited) {
+		t.Fatalf("unexpected err %v", err)
+	}
+}
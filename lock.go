@@ -0,0 +1,126 @@
+package cacheaside
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	defaultLockPollInterval = 20 * time.Millisecond
+	// lockKeyFormat 缓存 key 对应的回源锁 key
+	lockKeyFormat = "%s#lock"
+	// hashLockKeyFormat hash field 对应的回源锁 key
+	hashLockKeyFormat = "%s#lock#%s"
+)
+
+// ErrFillLockTimeout 等待其他进程回源填充缓存超时
+var ErrFillLockTimeout = errors.New("cacheaside: wait fill lock timeout")
+
+// fillWithLock 对 keys 加回源锁，加锁成功的 keys 由本进程回源填充，其余 keys 轮询缓存等待其他进程填充；
+// lockKey 生成 key 对应的锁 key，get 查询缓存，fill 回源并回写缓存，结果均写入 items
+func (_f *_Fetcher) fillWithLock(ctx context.Context, keys []string, items map[string]*item,
+	lockKey func(key string) string, get func(ctx context.Context, keys []string) (map[string]*item, error),
+	fill func(ctx context.Context, keys []string) error) error {
+	lockKey2Key := make(map[string]string, len(keys))
+	lockKeys := make([]string, 0, len(keys))
+	for _, key := range keys {
+		lk := lockKey(key)
+		lockKey2Key[lk] = key
+		lockKeys = append(lockKeys, lk)
+	}
+	token, err := lockToken()
+	if err != nil {
+		return fmt.Errorf("cacheaside: lock token error:%w", err)
+	}
+	locked, err := _f.opt.locker.MLock(ctx, token, _f.opt.lockTTL, lockKeys...)
+	if err != nil {
+		err = fmt.Errorf("cacheaside: locker.MLock error:%w", err)
+		if !_f.opt.lockFallback {
+			return err
+		}
+		_f.warn(ctx, err)
+		return fill(ctx, keys)
+	}
+
+	if len(locked) > 0 {
+		owned := make([]string, 0, len(locked))
+		for _, lk := range locked {
+			owned = append(owned, lockKey2Key[lk])
+			delete(lockKey2Key, lk)
+		}
+		err = fill(ctx, owned)
+		if e := _f.opt.locker.MUnlock(detachContext(ctx), token, locked...); e != nil {
+			_f.warn(ctx, fmt.Errorf("cacheaside: locker.MUnlock error:%w", e))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if len(lockKey2Key) == 0 {
+		return nil
+	}
+
+	waits := make([]string, 0, len(lockKey2Key))
+	for _, key := range keys {
+		if _, ok := lockKey2Key[lockKey(key)]; ok {
+			waits = append(waits, key)
+		}
+	}
+	rest, err := _f.waitFill(ctx, waits, items, get)
+	if err != nil {
+		return err
+	}
+	if len(rest) == 0 {
+		return nil
+	}
+	if !_f.opt.lockFallback {
+		return fmt.Errorf("%w, keys:%v", ErrFillLockTimeout, rest)
+	}
+	return fill(ctx, rest)
+}
+
+// waitFill 轮询缓存直到 keys 全部被填充或等待超时，返回仍未填充的 keys
+func (_f *_Fetcher) waitFill(ctx context.Context, keys []string, items map[string]*item,
+	get func(ctx context.Context, keys []string) (map[string]*item, error)) ([]string, error) {
+	timer := time.NewTimer(_f.opt.lockWait)
+	defer timer.Stop()
+	ticker := time.NewTicker(defaultLockPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("cacheaside: wait fill lock error:%w", ctx.Err())
+		case <-timer.C:
+			return keys, nil
+		case <-ticker.C:
+		}
+		got, err := get(ctx, keys)
+		if err != nil {
+			_f.warn(ctx, err)
+			continue
+		}
+		rest := keys[:0]
+		for _, key := range keys {
+			if it, ok := got[key]; ok {
+				items[key] = it
+				continue
+			}
+			rest = append(rest, key)
+		}
+		if keys = rest; len(keys) == 0 {
+			return nil, nil
+		}
+	}
+}
+
+func lockToken() (string, error) {
+	bs := make([]byte, 16)
+	if _, err := rand.Read(bs); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bs), nil
+}
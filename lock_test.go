@@ -0,0 +1,88 @@
+package cacheaside
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/erkesi/cacheaside/cache"
+	"github.com/erkesi/cacheaside/code"
+	"github.com/golang/mock/gomock"
+)
+
+func TestFillLock(t *testing.T) {
+	type User struct {
+		Id   string
+		Name string
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	bs, _ := json.Marshal(&User{Id: "2", Name: "name2"})
+	mcache := cache.NewMockCacher(ctrl)
+	mlocker := cache.NewMockLocker(ctrl)
+	gomock.InOrder(
+		mcache.EXPECT().MGet(gomock.Any(), "ns$1", "ns$2").Return(nil, nil),
+		mlocker.EXPECT().MLock(gomock.Any(), gomock.Any(), time.Second, "ns$1#lock", "ns$2#lock").
+			Return([]string{"ns$1#lock"}, nil),
+		mcache.EXPECT().MSet(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+			func(ctx context.Context, ttl *time.Duration, kvs ...*cache.KV) error {
+				if len(kvs) != 1 || kvs[0].Key != "ns$1" {
+					t.Fatalf("unexpected kvs %v", kvs)
+				}
+				return nil
+			}),
+		mlocker.EXPECT().MUnlock(gomock.Any(), gomock.Any(), "ns$1#lock").Return(nil),
+		mcache.EXPECT().MGet(gomock.Any(), "ns$2").Return(nil, nil),
+		mcache.EXPECT().MGet(gomock.Any(), "ns$2").Return(map[string][]byte{"ns$2": bs}, nil),
+	)
+
+	ca := NewCacheAside(&code.Json{}, mcache, "ns")
+	caf := ca.Fetch(func(ctx context.Context, keys []string, extra ...interface{}) ([]interface{}, error) {
+		if len(keys) != 1 || keys[0] != "ns$1" {
+			t.Fatalf("unexpected keys %v", keys)
+		}
+		return []interface{}{&User{Id: "1", Name: "name1"}}, nil
+	}, func(ctx context.Context, v interface{}, extra ...interface{}) (string, error) {
+		return v.(*User).Id, nil
+	}, WithFillLock(mlocker, time.Second, time.Second))
+
+	var us []*User
+	statuses, err := caf.MGetWithStatus(context.Background(), []string{"1", "2"}, &us)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(us) != 2 || us[0].Name != "name1" || us[1].Name != "name2" {
+		t.Fatalf("unexpected users %v", us)
+	}
+	if statuses[0] != StatusLoaded || statuses[1] != StatusHit {
+		t.Fatalf("unexpected statuses %v", statuses)
+	}
+}
+
+func TestFillLockTimeout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mcache := cache.NewMockCacher(ctrl)
+	mcache.EXPECT().MGet(gomock.Any(), "ns$1").Return(nil, nil).AnyTimes()
+	mlocker := cache.NewMockLocker(ctrl)
+	mlocker.EXPECT().MLock(gomock.Any(), gomock.Any(), gomock.Any(), "ns$1#lock").Return(nil, nil)
+
+	ca := NewCacheAside(&code.Json{}, mcache, "ns")
+	caf := ca.Fetch(func(ctx context.Context, keys []string, extra ...interface{}) ([]interface{}, error) {
+		t.Fatal("fetchSource should not be called")
+		return nil, nil
+	}, func(ctx context.Context, v interface{}, extra ...interface{}) (string, error) {
+		return "", nil
+	}, WithFillLock(mlocker, time.Second, 50*time.Millisecond), WithFillLockFallback(false))
+
+	var s string
+	_, err := caf.Get(context.Background(), "1", &s)
+	if !errors.Is(err, ErrFillLockTimeout) {
+		t.Fatalf("unexpected err %v", err)
+	}
+}
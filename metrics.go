@@ -0,0 +1,136 @@
+package cacheaside
+
+import (
+	"expvar"
+	"sync"
+	"time"
+)
+
+// Op 监控的操作
+type Op string
+
+const (
+	// OpCacheRead 查询缓存
+	OpCacheRead Op = "cache_read"
+	// OpCacheWrite 回源后写入缓存
+	OpCacheWrite Op = "cache_write"
+	// OpSource 回源
+	OpSource Op = "source"
+)
+
+// Metrics 监控指标，namespace 为 CacheAside 的 namespace（不含版本号）
+type Metrics interface {
+	// Hit 缓存命中的 key（field）数
+	Hit(namespace string, n int)
+	// Miss 缓存未命中的 key（field）数
+	Miss(namespace string, n int)
+	// NegativeHit 命中空值缓存的 key（field）数
+	NegativeHit(namespace string, n int)
+	// Load 回源获得（含确认不存在）的 key（field）数
+	Load(namespace string, n int)
+	// Error 操作失败
+	Error(namespace string, op Op)
+	// Observe 操作耗时
+	Observe(namespace string, op Op, d time.Duration)
+}
+
+// ExpvarMetrics 基于 expvar 的 Metrics 实现，按 namespace 分组发布计数与累计耗时（纳秒）
+type ExpvarMetrics struct {
+	root *expvar.Map
+	mu   sync.Mutex
+	nss  map[string]*expvar.Map
+}
+
+// NewExpvarMetrics 以 name 发布 expvar 变量，name 重复时 panic
+func NewExpvarMetrics(name string) *ExpvarMetrics {
+	return newExpvarMetrics(expvar.NewMap(name))
+}
+
+// newExpvarMetrics 使用未发布的 root，测试中避免重复发布同名变量
+func newExpvarMetrics(root *expvar.Map) *ExpvarMetrics {
+	return &ExpvarMetrics{
+		root: root,
+		nss:  make(map[string]*expvar.Map),
+	}
+}
+
+func (m *ExpvarMetrics) Hit(namespace string, n int) {
+	m.ns(namespace).Add("hit", int64(n))
+}
+
+func (m *ExpvarMetrics) Miss(namespace string, n int) {
+	m.ns(namespace).Add("miss", int64(n))
+}
+
+func (m *ExpvarMetrics) NegativeHit(namespace string, n int) {
+	m.ns(namespace).Add("negative_hit", int64(n))
+}
+
+func (m *ExpvarMetrics) Load(namespace string, n int) {
+	m.ns(namespace).Add("load", int64(n))
+}
+
+func (m *ExpvarMetrics) Error(namespace string, op Op) {
+	m.ns(namespace).Add(string(op)+"_error", 1)
+}
+
+func (m *ExpvarMetrics) Observe(namespace string, op Op, d time.Duration) {
+	ns := m.ns(namespace)
+	ns.Add(string(op)+"_count", 1)
+	ns.Add(string(op)+"_ns", int64(d))
+}
+
+func (m *ExpvarMetrics) ns(namespace string) *expvar.Map {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ns, ok := m.nss[namespace]
+	if !ok {
+		ns = new(expvar.Map).Init()
+		m.root.Set(namespace, ns)
+		m.nss[namespace] = ns
+	}
+	return ns
+}
+
+// observe 记录操作耗时与错误
+func (_f *_Fetcher) observe(op Op, start time.Time, err error) {
+	if _f.opt.metrics == nil {
+		return
+	}
+	_f.opt.metrics.Observe(_f.ca.namespance, op, time.Since(start))
+	if err != nil {
+		_f.opt.metrics.Error(_f.ca.namespance, op)
+	}
+}
+
+// count 记录缓存查询结果，miss 为未命中的 key（field）数
+func (_f *_Fetcher) count(items map[string]*item, miss int) {
+	if _f.opt.metrics == nil {
+		return
+	}
+	var hit, negativeHit int
+	for _, it := range items {
+		switch it.status {
+		case StatusHit:
+			hit++
+		case StatusNegativeHit:
+			negativeHit++
+		}
+	}
+	if hit > 0 {
+		_f.opt.metrics.Hit(_f.ca.namespance, hit)
+	}
+	if negativeHit > 0 {
+		_f.opt.metrics.NegativeHit(_f.ca.namespance, negativeHit)
+	}
+	if miss > 0 {
+		_f.opt.metrics.Miss(_f.ca.namespance, miss)
+	}
+}
+
+// loads 记录回源获得的 key（field）数
+func (_f *_Fetcher) loads(n int) {
+	if _f.opt.metrics != nil && n > 0 {
+		_f.opt.metrics.Load(_f.ca.namespance, n)
+	}
+}
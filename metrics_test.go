@@ -0,0 +1,53 @@
+package cacheaside
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"testing"
+	"time"
+
+	"github.com/erkesi/cacheaside/cache"
+	"github.com/erkesi/cacheaside/code"
+	"github.com/golang/mock/gomock"
+)
+
+func TestExpvarMetrics(t *testing.T) {
+	type User struct {
+		Id   string
+		Name string
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	bs, _ := json.Marshal(&User{Id: "1", Name: "name1"})
+	mcache := cache.NewMockCacher(ctrl)
+	mcache.EXPECT().MGet(gomock.Any(), "ns$1", "ns$2", "ns$3").Return(
+		map[string][]byte{"ns$1": bs, "ns$2": {}}, nil)
+	mcache.EXPECT().MSet(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+
+	root := new(expvar.Map).Init()
+	metrics := newExpvarMetrics(root)
+	ca := NewCacheAside(&code.Json{}, mcache, "ns")
+	caf := ca.Fetch(func(ctx context.Context, keys []string, extra ...interface{}) ([]interface{}, error) {
+		return []interface{}{&User{Id: "3", Name: "name3"}}, nil
+	}, func(ctx context.Context, v interface{}, extra ...interface{}) (string, error) {
+		return v.(*User).Id, nil
+	}, WithTTL(time.Hour), WithMetrics(metrics))
+
+	var us []*User
+	if err := caf.MGet(context.Background(), []string{"1", "2", "3"}, &us); err != nil {
+		t.Fatal(err)
+	}
+	ns := root.Get("ns").(*expvar.Map)
+	for key, want := range map[string]int64{"hit": 1, "negative_hit": 1, "miss": 1, "load": 1,
+		"cache_read_count": 1, "cache_write_count": 1, "source_count": 1} {
+		if v := ns.Get(key); v == nil || v.(*expvar.Int).Value() != want {
+			t.Fatalf("unexpected %s %v", key, v)
+		}
+	}
+	if ns.Get("source_error") != nil {
+		t.Fatal("unexpected source error")
+	}
+}
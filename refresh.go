@@ -0,0 +1,145 @@
+package cacheaside
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRefreshWorkers   = 4
+	defaultRefreshQueueSize = 1024
+)
+
+// refresher 软过期数据后台刷新的有界协程池
+type refresher struct {
+	tasks   chan func()
+	pending sync.Map
+	wg      sync.WaitGroup
+	mu      sync.RWMutex
+	closed  bool
+}
+
+func newRefresher(workers, queueSize int) *refresher {
+	if workers <= 0 {
+		workers = defaultRefreshWorkers
+	}
+	if queueSize <= 0 {
+		queueSize = defaultRefreshQueueSize
+	}
+	r := &refresher{
+		tasks: make(chan func(), queueSize),
+	}
+	r.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer r.wg.Done()
+			for task := range r.tasks {
+				task()
+			}
+		}()
+	}
+	return r
+}
+
+// submit 提交刷新任务，同一 key 的任务在完成前不会重复提交；已关闭、队列已满或任务已存在时返回 false
+func (r *refresher) submit(key string, task func()) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.closed {
+		return false
+	}
+	if _, loaded := r.pending.LoadOrStore(key, struct{}{}); loaded {
+		return false
+	}
+	select {
+	case r.tasks <- func() {
+		defer r.pending.Delete(key)
+		task()
+	}:
+		return true
+	default:
+		r.pending.Delete(key)
+		return false
+	}
+}
+
+// close 停止接收新任务，并等待已提交的任务执行完成
+func (r *refresher) close(ctx context.Context) error {
+	r.mu.Lock()
+	if !r.closed {
+		r.closed = true
+		close(r.tasks)
+	}
+	r.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("cacheaside: refresher close error:%w", ctx.Err())
+	}
+}
+
+// refreshPool CacheAside 下所有 Fetcher 共享的刷新协程池，首次有 Fetcher 开启软过期时创建
+type refreshPool struct {
+	workers   int
+	queueSize int
+	mu        sync.Mutex
+	r         *refresher
+	closed    bool
+}
+
+// get 返回协程池，已关闭时返回 nil
+func (p *refreshPool) get() *refresher {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil
+	}
+	if p.r == nil {
+		p.r = newRefresher(p.workers, p.queueSize)
+	}
+	return p.r
+}
+
+func (p *refreshPool) close(ctx context.Context) error {
+	p.mu.Lock()
+	p.closed = true
+	r := p.r
+	p.mu.Unlock()
+	if r == nil {
+		return nil
+	}
+	return r.close(ctx)
+}
+
+// detachedContext 保留 parent 中的 value，但不继承其取消信号与超时时间
+type detachedContext struct {
+	parent context.Context
+}
+
+func detachContext(ctx context.Context) context.Context {
+	return detachedContext{parent: ctx}
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
+
+func (d detachedContext) Value(key interface{}) interface{} {
+	return d.parent.Value(key)
+}
@@ -0,0 +1,181 @@
+package cacheaside
+
+import (
+	"context"
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/erkesi/cacheaside/cache"
+	"github.com/erkesi/cacheaside/cache/memory"
+	"github.com/erkesi/cacheaside/code"
+	"github.com/golang/mock/gomock"
+)
+
+func TestSoftTTLRefresh(t *testing.T) {
+	type User struct {
+		Id   string
+		Name string
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	stale, _ := json.Marshal(&User{Id: "1", Name: "stale"})
+	staleEnv := (&envelope{flags: flagSoftExpire, softExpire: time.Now().Add(-time.Second).UnixNano(),
+		data: stale}).encode()
+
+	mcache := cache.NewMockCacher(ctrl)
+	mcache.EXPECT().MGet(gomock.Any(), "ns$1").Return(map[string][]byte{"ns$1": staleEnv}, nil)
+	mcache.EXPECT().MSet(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, ttl *time.Duration, kvs ...*cache.KV) error {
+			if len(kvs) != 1 || kvs[0].Key != "ns$1" {
+				t.Fatalf("unexpected kvs %v", kvs)
+			}
+			env, ok := decodeEnvelope(kvs[0].Data)
+			if !ok || env.softExpire <= time.Now().UnixNano() {
+				t.Fatalf("unexpected envelope %v", env)
+			}
+			var u User
+			if err := json.Unmarshal(env.data, &u); err != nil || u.Name != "fresh" {
+				t.Fatalf("unexpected data %s", env.data)
+			}
+			return nil
+		})
+
+	var loads int32
+	ca := NewCacheAside(&code.Json{}, mcache, "ns")
+	caf := ca.Fetch(func(ctx context.Context, keys []string, extra ...interface{}) ([]interface{}, error) {
+		atomic.AddInt32(&loads, 1)
+		return []interface{}{&User{Id: "1", Name: "fresh"}}, nil
+	}, func(ctx context.Context, v interface{}, extra ...interface{}) (string, error) {
+		return v.(*User).Id, nil
+	}, WithTTL(time.Hour), WithSoftTTL(time.Minute))
+
+	var u User
+	ok, err := caf.Get(context.Background(), "1", &u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || u.Name != "stale" {
+		t.Fatalf("expected stale value, got %v", u)
+	}
+	if err = ca.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt32(&loads) != 1 {
+		t.Fatalf("expected one background load, got %d", loads)
+	}
+}
+
+func TestRefresherSubmit(t *testing.T) {
+	r := newRefresher(1, 1)
+	block := make(chan struct{})
+	if !r.submit("a", func() { <-block }) {
+		t.Fatal("submit a failed")
+	}
+	if r.submit("a", func() {}) {
+		t.Fatal("duplicate key should be rejected")
+	}
+	close(block)
+	if err := r.close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if r.submit("b", func() {}) {
+		t.Fatal("closed refresher should reject tasks")
+	}
+}
+
+func TestRefreshPoolShared(t *testing.T) {
+	ca := NewCacheAside(&code.Json{}, memory.New(), "ns")
+	fetch := func(opts ...OptFn) *Fetcher {
+		return ca.Fetch(func(ctx context.Context, keys []string, extra ...interface{}) ([]interface{}, error) {
+			return nil, nil
+		}, func(ctx context.Context, v interface{}, extra ...interface{}) (string, error) {
+			return "", nil
+		}, opts...)
+	}
+	if fetch().refresher != nil {
+		t.Fatal("refresher should not be created without soft ttl")
+	}
+	f1, f2 := fetch(WithSoftTTL(time.Minute)), fetch(WithSoftTTL(time.Minute))
+	if f1.refresher == nil || f1.refresher != f2.refresher {
+		t.Fatal("fetchers should share the refresher")
+	}
+	// 关闭 Fetcher 不影响共享的协程池
+	if err := f1.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !f2.refresher.submit("a", func() {}) {
+		t.Fatal("refresher should accept tasks")
+	}
+	if err := ca.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if fetch(WithSoftTTL(time.Minute)).refresher != nil {
+		t.Fatal("closed CacheAside should not create refresher")
+	}
+}
+
+func TestSoftTTLNotFound(t *testing.T) {
+	type User struct {
+		Id   string
+		Name string
+	}
+
+	ctx := context.Background()
+	var loads int32
+	ca := NewCacheAside(&code.Json{}, memory.New(), "ns")
+	caf := ca.Fetch(func(ctx context.Context, keys []string, extra ...interface{}) ([]interface{}, error) {
+		atomic.AddInt32(&loads, 1)
+		return nil, nil
+	}, func(ctx context.Context, v interface{}, extra ...interface{}) (string, error) {
+		return v.(*User).Id, nil
+	}, WithTTL(time.Hour), WithSoftTTL(time.Minute))
+	tf := NewTypedFetcher[string, *User](ca, func(ctx context.Context, keys []string,
+		extra ...interface{}) ([]*User, error) {
+		atomic.AddInt32(&loads, 1)
+		return nil, nil
+	}, func(ctx context.Context, v *User, extra ...interface{}) (string, error) {
+		return v.Id, nil
+	}, WithTTL(time.Hour), WithSoftTTL(time.Minute))
+
+	// 第二次读取命中缓存的空值
+	for i := 0; i < 2; i++ {
+		var us []*User
+		if err := caf.MGet(ctx, []string{"1"}, &us); err != nil {
+			t.Fatal(err)
+		}
+		if us[0] != nil {
+			t.Fatalf("unexpected user %v", us[0])
+		}
+		res, err := tf.MGet(ctx, []string{"2"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res) != 0 {
+			t.Fatalf("unexpected res %v", res)
+		}
+	}
+
+	hca := NewHCacheAside(&code.Json{}, memory.New(), "ns")
+	hcaf := hca.HFetch(func(ctx context.Context, key string, fields []string, extra ...interface{}) ([]interface{}, error) {
+		atomic.AddInt32(&loads, 1)
+		return []interface{}{&User{Id: "1", Name: "name1"}}, nil
+	}, func(ctx context.Context, v interface{}, extra ...interface{}) (string, error) {
+		return v.(*User).Id, nil
+	}, WithTTL(time.Hour), WithSoftTTL(time.Minute))
+	for i := 0; i < 2; i++ {
+		var us []*User
+		if err := hcaf.HMGet(ctx, "h", []string{"1", "2"}, &us); err != nil {
+			t.Fatal(err)
+		}
+		if len(us) != 2 || us[0] == nil || us[1] != nil {
+			t.Fatalf("unexpected users %v", us)
+		}
+	}
+	if n := atomic.LoadInt32(&loads); n != 3 {
+		t.Fatalf("expected 3 loads, got %d", n)
+	}
+}
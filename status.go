@@ -0,0 +1,70 @@
+package cacheaside
+
+import "fmt"
+
+// Status 单个 key（field）的查询状态
+type Status int
+
+const (
+	// StatusMiss 缓存未命中且未回源（如 StrategyOnlyUseCache）或回源失败
+	StatusMiss Status = iota
+	// StatusHit 缓存命中
+	StatusHit
+	// StatusNegativeHit 命中空值缓存，即之前回源已确认不存在
+	StatusNegativeHit
+	// StatusLoaded 回源获得
+	StatusLoaded
+	// StatusNotFound 回源确认不存在
+	StatusNotFound
+	// StatusStale 回源被限流，返回缓存中已过期的旧数据（LimitPolicyServeStale）
+	StatusStale
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusMiss:
+		return "Miss"
+	case StatusHit:
+		return "Hit"
+	case StatusNegativeHit:
+		return "NegativeHit"
+	case StatusLoaded:
+		return "Loaded"
+	case StatusNotFound:
+		return "NotFound"
+	case StatusStale:
+		return "Stale"
+	}
+	return "Unknown"
+}
+
+// statuses 按 keys 顺序返回查询状态
+func statuses(keys []string, items map[string]*item) []Status {
+	res := make([]Status, len(keys))
+	for i, key := range keys {
+		if it, ok := items[key]; ok {
+			res[i] = it.status
+		}
+	}
+	return res
+}
+
+// itemsErr 按 keys 顺序返回第一个回源失败的错误
+func itemsErr(keys []string, items map[string]*item) error {
+	for _, key := range keys {
+		if it, ok := items[key]; ok && it.err != nil {
+			return fmt.Errorf("cacheaside: key %s error:%w", key, it.err)
+		}
+	}
+	return nil
+}
+
+// MGetResult 单个 key 的查询结果
+type MGetResult struct {
+	Key string
+	// Val 查询到的值，不存在或回源失败时为 nil
+	Val    interface{}
+	Status Status
+	// Err 回源失败的错误
+	Err error
+}
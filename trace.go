@@ -0,0 +1,84 @@
+package cacheaside
+
+import (
+	"context"
+)
+
+const (
+	spanGet        = "cacheaside.Get"
+	spanMGet       = "cacheaside.MGet"
+	spanHGet       = "cacheaside.HGet"
+	spanHMGet      = "cacheaside.HMGet"
+	spanCacheRead  = "cacheaside.cache_read"
+	spanCacheWrite = "cacheaside.cache_write"
+	spanWait       = "cacheaside.singleflight_wait"
+	spanSource     = "cacheaside.fetch_source"
+	spanEncode     = "cacheaside.encode"
+	spanDecode     = "cacheaside.decode"
+)
+
+const (
+	attrNamespace = "cacheaside.namespace"
+	attrKeyCount  = "cacheaside.key_count"
+	attrHitCount  = "cacheaside.hit_count"
+	attrStrategy  = "cacheaside.strategy"
+)
+
+// Tracer 链路追踪，caotel 提供了基于 OpenTelemetry 的实现
+type Tracer interface {
+	// Start 开始 span，返回带有 span 的 ctx
+	Start(ctx context.Context, name string, attrs ...Attr) (context.Context, Span)
+}
+
+// Span 链路追踪的 span
+type Span interface {
+	SetAttributes(attrs ...Attr)
+	// End 结束 span，err 不为空时记录错误
+	End(err error)
+}
+
+// Attr span 属性，Value 为 string、int、int64、float64、bool 之一，其他类型按字符串记录
+type Attr struct {
+	Key   string
+	Value interface{}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(attrs ...Attr) {}
+
+func (noopSpan) End(err error) {}
+
+// startSpan 开始 span，未设置 Tracer 时返回空 span
+func (_f *_Fetcher) startSpan(ctx context.Context, name string, attrs ...Attr) (context.Context, Span) {
+	if _f.opt.tracer == nil {
+		return ctx, noopSpan{}
+	}
+	return _f.opt.tracer.Start(ctx, name, attrs...)
+}
+
+// startReadSpan 开始一次读取的 span
+func (_f *_Fetcher) startReadSpan(ctx context.Context, name string, keyCount int) (context.Context, Span) {
+	if _f.opt.tracer == nil {
+		return ctx, noopSpan{}
+	}
+	return _f.opt.tracer.Start(ctx, name,
+		Attr{Key: attrNamespace, Value: _f.ca.namespance},
+		Attr{Key: attrKeyCount, Value: keyCount},
+		Attr{Key: attrStrategy, Value: string(_f.opt.strategy())})
+}
+
+// endReadSpan 记录命中数并结束读取的 span，err 为空时记录 keys 中第一个回源失败的 key
+func endReadSpan(span Span, keys []string, items map[string]*item, err error) {
+	hit := 0
+	for _, it := range items {
+		if it.status == StatusHit || it.status == StatusNegativeHit {
+			hit++
+		}
+	}
+	span.SetAttributes(Attr{Key: attrHitCount, Value: hit})
+	if err == nil {
+		err = itemsErr(keys, items)
+	}
+	span.End(err)
+}
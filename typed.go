@@ -0,0 +1,424 @@
+package cacheaside
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/erkesi/cacheaside/code"
+)
+
+// TypedFetchSource 回源查询（泛型），keys 为调用方传入的原始 key（不带 namespace）
+type TypedFetchSource[K comparable, V any] func(ctx context.Context, keys []K,
+	extra ...interface{}) ([]V, error)
+
+// TypedGenCacheKey 回源查询到的结果项（v）生成 key
+type TypedGenCacheKey[K comparable, V any] func(ctx context.Context, v V,
+	extra ...interface{}) (K, error)
+
+// TypedFetchSourceHash 回源查询 hash（泛型），key 为调用方传入的原始 key（不带 namespace）
+type TypedFetchSourceHash[V any] func(ctx context.Context, key string, fields []string,
+	extra ...interface{}) ([]V, error)
+
+// TypedGenCacheHashField 回源查询到的结果项（v）生成 hash field
+type TypedGenCacheHashField[V any] func(ctx context.Context, v V,
+	extra ...interface{}) (string, error)
+
+// TypedFetcher 类型安全的 Fetcher，K 通过 fmt.Sprint 转换为缓存 key
+type TypedFetcher[K comparable, V any] struct {
+	f           *Fetcher
+	fetchSource TypedFetchSource[K, V]
+}
+
+// TypedHFetcher 类型安全的 HFetcher
+type TypedHFetcher[V any] struct {
+	hf          *HFetcher
+	fetchSource TypedFetchSourceHash[V]
+}
+
+func NewTypedFetcher[K comparable, V any](ca *CacheAside, fetchSource TypedFetchSource[K, V],
+	genCacheKey TypedGenCacheKey[K, V], opts ...OptFn) *TypedFetcher[K, V] {
+	var gen GenCacheKey
+	if genCacheKey != nil {
+		gen = func(ctx context.Context, v interface{}, extra ...interface{}) (string, error) {
+			k, err := genCacheKey(ctx, v.(V), extra...)
+			if err != nil {
+				return "", err
+			}
+			return keyString(k), nil
+		}
+	}
+	return &TypedFetcher[K, V]{
+		f:           ca.Fetch(nil, gen, opts...),
+		fetchSource: fetchSource,
+	}
+}
+
+func NewTypedHFetcher[V any](ca *CacheAside, fetchSource TypedFetchSourceHash[V],
+	genCacheHashField TypedGenCacheHashField[V], opts ...OptFn) *TypedHFetcher[V] {
+	var gen GenCacheHashField
+	if genCacheHashField != nil {
+		gen = func(ctx context.Context, v interface{}, extra ...interface{}) (string, error) {
+			return genCacheHashField(ctx, v.(V), extra...)
+		}
+	}
+	return &TypedHFetcher[V]{
+		hf:          ca.HFetch(nil, gen, opts...),
+		fetchSource: fetchSource,
+	}
+}
+
+func (tf *TypedFetcher[K, V]) Get(ctx context.Context, key K, extra ...interface{}) (V, bool, error) {
+	var v V
+	cacheKeys, items, err := tf.fetch(ctx, spanGet, []K{key}, extra...)
+	if err != nil {
+		return v, false, err
+	}
+	return decodeItem[V](tf.f.ca.code, items[cacheKeys[0]])
+}
+
+// MGet 查询多个 key，结果中仅包含存在的 key
+func (tf *TypedFetcher[K, V]) MGet(ctx context.Context, keys []K, extra ...interface{}) (map[K]V, error) {
+	cacheKeys, items, err := tf.fetch(ctx, spanMGet, keys, extra...)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[K]V, len(keys))
+	for i, key := range keys {
+		v, ok, err := decodeItem[V](tf.f.ca.code, items[cacheKeys[i]])
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			res[key] = v
+		}
+	}
+	return res, nil
+}
+
+// MGetSlice 查询多个 key，结果与 keys 一一对应，不存在的 key 为零值
+func (tf *TypedFetcher[K, V]) MGetSlice(ctx context.Context, keys []K, extra ...interface{}) ([]V, error) {
+	cacheKeys, items, err := tf.fetch(ctx, spanMGet, keys, extra...)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]V, len(keys))
+	for i := range keys {
+		res[i], _, err = decodeItem[V](tf.f.ca.code, items[cacheKeys[i]])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// MGetWithStatus 同 MGetSlice，同时返回与 keys 一一对应的查询状态
+func (tf *TypedFetcher[K, V]) MGetWithStatus(ctx context.Context, keys []K,
+	extra ...interface{}) ([]V, []Status, error) {
+	cacheKeys, items, err := tf.fetch(ctx, spanMGet, keys, extra...)
+	if err != nil {
+		return nil, nil, err
+	}
+	res := make([]V, len(keys))
+	for i := range keys {
+		res[i], _, err = decodeItem[V](tf.f.ca.code, items[cacheKeys[i]])
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return res, statuses(cacheKeys, items), nil
+}
+
+func (tf *TypedFetcher[K, V]) Set(ctx context.Context, value V) error {
+	return tf.MSet(ctx, value)
+}
+
+// MSet 写入多个值到缓存（write-through）
+func (tf *TypedFetcher[K, V]) MSet(ctx context.Context, values ...V) error {
+	if err := tf.check(); err != nil {
+		return err
+	}
+	return tf.f.mset(ctx, toInterfaces(values))
+}
+
+func (tf *TypedFetcher[K, V]) MDel(ctx context.Context, keys ...K) error {
+	if err := tf.check(); err != nil {
+		return err
+	}
+	ns, err := tf.f.namespace(ctx)
+	if err != nil {
+		return err
+	}
+	return tf.f.ca.cache.MDel(ctx, tf.cacheKeys(ns, keys)...)
+}
+
+// MDelDelayed 延迟双删：立即删除 keys，并在 delay 后再次删除
+func (tf *TypedFetcher[K, V]) MDelDelayed(ctx context.Context, delay time.Duration, keys ...K) error {
+	if err := tf.check(); err != nil {
+		return err
+	}
+	ns, err := tf.f.namespace(ctx)
+	if err != nil {
+		return err
+	}
+	return tf.f.mdelDelayed(ctx, delay, tf.cacheKeys(ns, keys))
+}
+
+// Close 立即执行尚未到期的延迟删除，并等待进行中的任务完成；后台刷新由 CacheAside.Close 停止
+func (tf *TypedFetcher[K, V]) Close(ctx context.Context) error {
+	return tf.f.Close(ctx)
+}
+
+func (tf *TypedFetcher[K, V]) fetch(ctx context.Context, spanName string, keys []K,
+	extra ...interface{}) (_ []string, items map[string]*item, err error) {
+	ctx, span := tf.f.startReadSpan(ctx, spanName, len(keys))
+	defer func() {
+		endReadSpan(span, nil, items, err)
+	}()
+	if err := tf.check(); err != nil {
+		return nil, nil, err
+	}
+	ns, err := tf.f.namespace(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	cacheKeys := tf.cacheKeys(ns, keys)
+	cacheKey2Key := make(map[string]K, len(keys))
+	for i, key := range keys {
+		cacheKey2Key[cacheKeys[i]] = key
+	}
+	items, err = tf.f.fetch(ctx, ns, cacheKeys, partialSource(func(ctx context.Context, keys []string,
+		extra ...interface{}) ([]interface{}, error) {
+		ks := make([]K, 0, len(keys))
+		for _, key := range keys {
+			ks = append(ks, cacheKey2Key[key])
+		}
+		vs, err := tf.fetchSource(ctx, ks, extra...)
+		if err != nil {
+			return nil, err
+		}
+		return toInterfaces(vs), nil
+	}), extra...)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = itemsErr(cacheKeys, items); err != nil {
+		return nil, nil, err
+	}
+	return cacheKeys, items, nil
+}
+
+func (tf *TypedFetcher[K, V]) cacheKeys(ns string, keys []K) []string {
+	cacheKeys := make([]string, 0, len(keys))
+	for _, key := range keys {
+		cacheKeys = append(cacheKeys, tf.f.cacheKey(ns, keyString(key)))
+	}
+	return cacheKeys
+}
+
+func (tf *TypedFetcher[K, V]) check() error {
+	if tf.fetchSource == nil {
+		return errors.New("cacheaside: fetchSource is nil")
+	}
+	if tf.f.genCacheKey == nil {
+		return errors.New("cacheaside: genCacheKey is nil")
+	}
+	return tf.f._check(true, false)
+}
+
+func (thf *TypedHFetcher[V]) HGet(ctx context.Context, key, field string, extra ...interface{}) (V, bool, error) {
+	var v V
+	items, err := thf.fetch(ctx, spanHGet, key, []string{field}, extra...)
+	if err != nil {
+		return v, false, err
+	}
+	return decodeItem[V](thf.hf.ca.code, items[field])
+}
+
+// HMGet 查询 hash 多个 field，结果中仅包含存在的 field
+func (thf *TypedHFetcher[V]) HMGet(ctx context.Context, key string, fields []string,
+	extra ...interface{}) (map[string]V, error) {
+	items, err := thf.fetch(ctx, spanHMGet, key, fields, extra...)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]V, len(fields))
+	for _, field := range fields {
+		v, ok, err := decodeItem[V](thf.hf.ca.code, items[field])
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			res[field] = v
+		}
+	}
+	return res, nil
+}
+
+// HMGetSlice 查询 hash 多个 field，结果与 fields 一一对应，不存在的 field 为零值
+func (thf *TypedHFetcher[V]) HMGetSlice(ctx context.Context, key string, fields []string,
+	extra ...interface{}) ([]V, error) {
+	items, err := thf.fetch(ctx, spanHMGet, key, fields, extra...)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]V, len(fields))
+	for i, field := range fields {
+		res[i], _, err = decodeItem[V](thf.hf.ca.code, items[field])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// HMGetWithStatus 同 HMGetSlice，同时返回与 fields 一一对应的查询状态
+func (thf *TypedHFetcher[V]) HMGetWithStatus(ctx context.Context, key string, fields []string,
+	extra ...interface{}) ([]V, []Status, error) {
+	items, err := thf.fetch(ctx, spanHMGet, key, fields, extra...)
+	if err != nil {
+		return nil, nil, err
+	}
+	res := make([]V, len(fields))
+	for i, field := range fields {
+		res[i], _, err = decodeItem[V](thf.hf.ca.code, items[field])
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return res, statuses(fields, items), nil
+}
+
+func (thf *TypedHFetcher[V]) HSet(ctx context.Context, key string, value V) error {
+	return thf.HMSet(ctx, key, value)
+}
+
+// HMSet 写入多个值到 hash 缓存（write-through）
+func (thf *TypedHFetcher[V]) HMSet(ctx context.Context, key string, values ...V) error {
+	if err := thf.check(); err != nil {
+		return err
+	}
+	return thf.hf.hmSet(ctx, key, toInterfaces(values))
+}
+
+func (thf *TypedHFetcher[V]) HMDel(ctx context.Context, key string, fields ...string) error {
+	if err := thf.check(); err != nil {
+		return err
+	}
+	hashKey, err := thf.hf.hashKey(ctx, key)
+	if err != nil {
+		return err
+	}
+	return thf.hf.ca.hcache.HMDel(ctx, hashKey, fields...)
+}
+
+func (thf *TypedHFetcher[V]) HDel(ctx context.Context, key string) error {
+	if err := thf.check(); err != nil {
+		return err
+	}
+	hashKey, err := thf.hf.hashKey(ctx, key)
+	if err != nil {
+		return err
+	}
+	return thf.hf.ca.hcache.HDel(ctx, hashKey)
+}
+
+// HMDelDelayed 延迟双删 hash 多个 field：立即删除，并在 delay 后再次删除
+func (thf *TypedHFetcher[V]) HMDelDelayed(ctx context.Context, delay time.Duration, key string, fields ...string) error {
+	if err := thf.check(); err != nil {
+		return err
+	}
+	hashKey, err := thf.hf.hashKey(ctx, key)
+	if err != nil {
+		return err
+	}
+	return thf.hf.hmDelDelayed(ctx, delay, hashKey, fields)
+}
+
+// HDelDelayed 延迟双删 hash key：立即删除，并在 delay 后再次删除
+func (thf *TypedHFetcher[V]) HDelDelayed(ctx context.Context, delay time.Duration, key string) error {
+	if err := thf.check(); err != nil {
+		return err
+	}
+	hashKey, err := thf.hf.hashKey(ctx, key)
+	if err != nil {
+		return err
+	}
+	return thf.hf.hDelDelayed(ctx, delay, hashKey)
+}
+
+// Close 立即执行尚未到期的延迟删除，并等待进行中的任务完成；后台刷新由 CacheAside.Close 停止
+func (thf *TypedHFetcher[V]) Close(ctx context.Context) error {
+	return thf.hf.Close(ctx)
+}
+
+func (thf *TypedHFetcher[V]) fetch(ctx context.Context, spanName string, key string, fields []string,
+	extra ...interface{}) (items map[string]*item, err error) {
+	ctx, span := thf.hf.startReadSpan(ctx, spanName, len(fields))
+	defer func() {
+		endReadSpan(span, fields, items, err)
+	}()
+	if err := thf.check(); err != nil {
+		return nil, err
+	}
+	hashKey, err := thf.hf.hashKey(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return thf.hf.fetch(ctx, hashKey, fields, func(ctx context.Context, _ string, fields []string,
+		extra ...interface{}) ([]interface{}, error) {
+		vs, err := thf.fetchSource(ctx, key, fields, extra...)
+		if err != nil {
+			return nil, err
+		}
+		return toInterfaces(vs), nil
+	}, extra...)
+}
+
+func (thf *TypedHFetcher[V]) check() error {
+	if thf.fetchSource == nil {
+		return errors.New("cacheaside: fetchSource is nil")
+	}
+	if thf.hf.genCacheHashField == nil {
+		return errors.New("cacheaside: genCacheHashField is nil")
+	}
+	return thf.hf._check(false, true)
+}
+
+// decodeItem 将查询结果转换为 V，值不存在（或解码为零值）时返回 false
+func decodeItem[V any](coder code.Coder, it *item) (V, bool, error) {
+	var v V
+	if it == nil {
+		return v, false, nil
+	}
+	if it.status == StatusLoaded {
+		return it.val.(V), true, nil
+	}
+	if it.status != StatusHit && it.status != StatusStale {
+		return v, false, nil
+	}
+	if err := coder.Decode(it.data, &v); err != nil {
+		return v, false, err
+	}
+	if reflect.ValueOf(&v).Elem().IsZero() {
+		return v, false, nil
+	}
+	return v, true, nil
+}
+
+func toInterfaces[V any](vs []V) []interface{} {
+	res := make([]interface{}, 0, len(vs))
+	for _, v := range vs {
+		res = append(res, v)
+	}
+	return res
+}
+
+func keyString[K comparable](key K) string {
+	if s, ok := interface{}(key).(string); ok {
+		return s
+	}
+	return fmt.Sprint(key)
+}
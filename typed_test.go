@@ -0,0 +1,109 @@
+package cacheaside
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/erkesi/cacheaside/cache"
+	"github.com/erkesi/cacheaside/code"
+	"github.com/golang/mock/gomock"
+)
+
+func TestTypedFetcher(t *testing.T) {
+	type User struct {
+		Id   int
+		Name string
+	}
+
+	genUser := func(id int) *User {
+		return &User{Id: id, Name: "name" + strconv.Itoa(id)}
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mcache := cache.NewMockCacher(ctrl)
+	mcache.EXPECT().MGet(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, keys ...string) (map[string][]byte, error) {
+		if len(keys) != 3 || keys[0] != "ns$1" || keys[1] != "ns$2" || keys[2] != "ns$3" {
+			t.Fatalf("unexpected keys %v", keys)
+		}
+		bs, _ := json.Marshal(genUser(1))
+		return map[string][]byte{"ns$1": bs}, nil
+	})
+	mcache.EXPECT().MSet(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, ttl *time.Duration, kvs ...*cache.KV) error {
+			if len(kvs) != 2 || kvs[0].Key != "ns$2" || kvs[1].Key != "ns$3" || len(kvs[1].Data) != 0 {
+				t.Fatalf("unexpected kvs %v", kvs)
+			}
+			return nil
+		})
+	mcache.EXPECT().MDel(gomock.Any(), "ns$1", "ns$2").Return(nil)
+	mcache.EXPECT().MSet(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, ttl *time.Duration, kvs ...*cache.KV) error {
+			if len(kvs) != 1 || kvs[0].Key != "ns$4" || string(kvs[0].Data) != `{"Id":4,"Name":"name4"}` {
+				t.Fatalf("unexpected kvs %v", kvs)
+			}
+			return nil
+		})
+
+	ca := NewCacheAside(&code.Json{}, mcache, "ns")
+	tf := NewTypedFetcher(ca, func(ctx context.Context, ids []int, extra ...interface{}) ([]*User, error) {
+		if len(ids) != 2 || ids[0] != 2 || ids[1] != 3 {
+			t.Fatalf("unexpected ids %v", ids)
+		}
+		return []*User{genUser(2)}, nil
+	}, func(ctx context.Context, u *User, extra ...interface{}) (int, error) {
+		return u.Id, nil
+	}, WithTTL(time.Hour))
+
+	us, err := tf.MGet(context.Background(), []int{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(us) != 2 || us[1].Name != "name1" || us[2].Name != "name2" {
+		t.Fatalf("unexpected users %v", us)
+	}
+	if _, ok := us[3]; ok {
+		t.Fatal("user 3 should not exist")
+	}
+	err = tf.MDel(context.Background(), 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tf.Set(context.Background(), genUser(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTypedHFetcher(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mhcache := cache.NewMockHCacher(ctrl)
+	mhcache.EXPECT().HMGet(gomock.Any(), "ns$1", "Name", "Age").Return(
+		map[string][]byte{"Name": []byte(`"tom"`)}, nil)
+	mhcache.EXPECT().HMSet(gomock.Any(), "ns$1", gomock.Any(), gomock.Any()).Return(nil)
+
+	ca := NewHCacheAside(&code.Json{}, mhcache, "ns")
+	thf := NewTypedHFetcher(ca, func(ctx context.Context, key string, fields []string,
+		extra ...interface{}) ([]string, error) {
+		if key != "1" || len(fields) != 1 || fields[0] != "Age" {
+			t.Fatalf("unexpected key %s fields %v", key, fields)
+		}
+		return []string{"Age:20"}, nil
+	}, func(ctx context.Context, v string, extra ...interface{}) (string, error) {
+		return "Age", nil
+	})
+
+	vs, err := thf.HMGetSlice(context.Background(), "1", []string{"Name", "Age"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vs) != 2 || vs[0] != "tom" || vs[1] != "Age:20" {
+		t.Fatalf("unexpected values %v", vs)
+	}
+}